@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+
+	"github.com/yuhlau/go-sns-message-validator/snsfake"
+)
+
+func main() {
+	var signatureVersion string
+	var tlsCertPath string
+
+	flag.StringVar(&signatureVersion, "signatureVersion", "1", "Signature version of the delivered messages")
+	flag.StringVar(&tlsCertPath, "tlsCertPath", "", "Path to write the TLS certificate of the server to, for clients to trust")
+
+	flag.Parse()
+
+	server := snsfake.NewServer()
+	defer server.Close()
+	server.SignatureVersion = signatureVersion
+
+	if tlsCertPath != "" {
+		tlsCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		if err := ioutil.WriteFile(tlsCertPath, tlsCert, 0644); err != nil {
+			fmt.Printf("Write TLS certificate error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Fake SNS listening on %s\n", server.URL)
+	fmt.Printf("Signing certificate URL: %s\n", server.CertURL)
+	fmt.Printf("Topic ARN of \"MyTopic\": %s\n", snsfake.TopicArn("MyTopic"))
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+}
@@ -0,0 +1,410 @@
+// Command sns-validate validates SNS messages read from files or stdin, and
+// prints the result of each message.
+//
+// Usage:
+//
+//	sns-validate [flags] [file ...]
+//
+// Each file may contain one or more JSON-encoded SNS messages. If no file is
+// given, or the file is "-", the messages are read from stdin. The exit status
+// is 1 if any message is invalid, and 2 on usage or read errors.
+//
+// The flags are:
+//
+//	-certDir dir
+//		Read the signing certificates from dir, named by the last path
+//		segment of "SigningCertURL", before retrieving them from the URL
+//	-offline
+//		Never retrieve the signing certificates from the URL. Requires
+//		-certDir
+//	-trustedHost pattern
+//		Regular expression of the trusted hosts of "SigningCertURL",
+//		instead of the AWS SNS hosts
+//	-maxAge duration
+//		Reject messages older than duration by their "Timestamp"
+//	-signatureVersion version
+//		Accept the signature version only, either 1 or 2
+//	-json
+//		Print the results as a JSON array
+//	-explain
+//		Print how the signature of each message is verified: the signable
+//		string with visible newlines, the digest, the decoded signature
+//		length, the signing certificate and the failed step, if any. A
+//		message failing before the signature verification is not explained
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Exit status of the command
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+// options records the validation options given by the flags
+type options struct {
+	certFetcher       snsvalidator.CertFetcher
+	hostPattern       *regexp.Regexp
+	maxAge            time.Duration
+	signatureVersions []string
+	explain           bool
+
+	// verifier validates the messages with the options, caching the signing
+	// certificates across the messages
+	verifier *snsvalidator.Verifier
+}
+
+// result records the validation result of a message
+type result struct {
+	Source    string      `json:"source"`
+	Index     int         `json:"index"`
+	Type      string      `json:"type,omitempty"`
+	MessageId string      `json:"messageId,omitempty"`
+	Valid     bool        `json:"valid"`
+	Error     interface{} `json:"error,omitempty"`
+
+	Explanation *explanation `json:"explanation,omitempty"`
+
+	err error
+}
+
+// explanation records how the signature of a message is verified
+type explanation struct {
+	SignableString   string       `json:"signableString"`
+	SignatureVersion string       `json:"signatureVersion"`
+	Hash             string       `json:"hash,omitempty"`
+	Digest           string       `json:"digest,omitempty"`
+	SignatureLength  int          `json:"signatureLength"`
+	Certificate      *certificate `json:"certificate,omitempty"`
+	FailedStep       string       `json:"failedStep,omitempty"`
+}
+
+// certificate records the signing certificate of a message
+type certificate struct {
+	Subject     string    `json:"subject"`
+	NotAfter    time.Time `json:"notAfter"`
+	Fingerprint string    `json:"sha256Fingerprint"`
+}
+
+// fallbackCertFetcher retrieves the signing certificate from the directory,
+// or from the URL if it is absent from the directory
+type fallbackCertFetcher struct {
+	dir  snsvalidator.DirCertFetcher
+	http snsvalidator.HTTPCertFetcher
+}
+
+func (fetcher fallbackCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	certData, err := fetcher.dir.FetchCertificate(ctx, certURL)
+	if errors.Is(err, os.ErrNotExist) {
+		return fetcher.http.FetchCertificate(ctx, certURL)
+	}
+	return certData, err
+}
+
+// onceCertFetcher retrieves the signing certificate of a message once with
+// the underlying CertFetcher, and returns the same result, including failure,
+// when the signature is explained after the validation
+type onceCertFetcher struct {
+	fetcher snsvalidator.CertFetcher
+
+	fetched  bool
+	certData []byte
+	err      error
+}
+
+func (once *onceCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	if !once.fetched {
+		once.certData, once.err = once.fetcher.FetchCertificate(ctx, certURL)
+		once.fetched = true
+	}
+	return once.certData, once.err
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with the arguments and returns the exit status
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	var certDir string
+	var offline bool
+	var trustedHost string
+	var maxAge time.Duration
+	var signatureVersion string
+	var jsonOutput bool
+	var explain bool
+
+	flags := flag.NewFlagSet("sns-validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&certDir, "certDir", "", "Directory of the signing certificates")
+	flags.BoolVar(&offline, "offline", false, "Never retrieve the signing certificates from the URL")
+	flags.StringVar(&trustedHost, "trustedHost", "", "Regular expression of the trusted certificate hosts")
+	flags.DurationVar(&maxAge, "maxAge", 0, "Maximum age of the messages")
+	flags.StringVar(&signatureVersion, "signatureVersion", "", "Accepted signature version")
+	flags.BoolVar(&jsonOutput, "json", false, "Whether to print the results as JSON")
+	flags.BoolVar(&explain, "explain", false, "Whether to print how the signatures are verified")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	opts, err := newOptions(certDir, offline, trustedHost, maxAge, signatureVersion)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	opts.explain = explain
+
+	sources := flags.Args()
+	if len(sources) == 0 {
+		sources = []string{"-"}
+	}
+
+	var results []result
+	for _, source := range sources {
+		sourceResults, err := validateSource(source, stdin, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "Read %s error: %v\n", source, err)
+			return exitUsage
+		}
+		results = append(results, sourceResults...)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+	} else {
+		for _, r := range results {
+			printResult(stdout, r)
+		}
+	}
+
+	for _, r := range results {
+		if !r.Valid {
+			return exitInvalid
+		}
+	}
+	return exitValid
+}
+
+// newOptions returns the validation options of the flags
+func newOptions(
+	certDir string, offline bool, trustedHost string, maxAge time.Duration, signatureVersion string,
+) (*options, error) {
+	opts := &options{maxAge: maxAge}
+
+	if certDir != "" {
+		if offline {
+			opts.certFetcher = snsvalidator.DirCertFetcher(certDir)
+		} else {
+			opts.certFetcher = fallbackCertFetcher{dir: snsvalidator.DirCertFetcher(certDir)}
+		}
+	} else if offline {
+		return nil, errors.New("-offline requires -certDir")
+	}
+
+	if trustedHost != "" {
+		hostPattern, err := regexp.Compile(trustedHost)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid -trustedHost: %v", err)
+		}
+		opts.hostPattern = hostPattern
+	}
+
+	switch signatureVersion {
+	case "":
+	case snsvalidator.SignatureVersion1, snsvalidator.SignatureVersion2:
+		opts.signatureVersions = []string{signatureVersion}
+	default:
+		return nil, fmt.Errorf("Invalid -signatureVersion \"%s\"", signatureVersion)
+	}
+
+	verifierOptions := []snsvalidator.VerifierOption{
+		snsvalidator.WithHostPattern(opts.hostPattern),
+		snsvalidator.WithMaxAge(opts.maxAge),
+		snsvalidator.WithSignatureVersions(opts.signatureVersions...),
+	}
+	if opts.certFetcher != nil {
+		verifierOptions = append(verifierOptions, snsvalidator.WithCertFetcher(opts.certFetcher))
+	}
+	opts.verifier = snsvalidator.NewVerifier(verifierOptions...)
+
+	return opts, nil
+}
+
+// validateSource validates the messages of the source file, or stdin if the
+// source is "-"
+func validateSource(source string, stdin io.Reader, opts *options) ([]result, error) {
+	reader := stdin
+	if source == "-" {
+		source = "stdin"
+	} else {
+		fp, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		reader = fp
+	}
+
+	var results []result
+	decoder := json.NewDecoder(reader)
+	for index := 1; ; index++ {
+		var encoded json.RawMessage
+		err := decoder.Decode(&encoded)
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			// The rest of the source cannot be decoded
+			err = snserrors.Wrap(snsmessage.ErrTypeMalformedJSON, err, "")
+			return append(results, newResult(source, index, nil, err)), nil
+		}
+
+		message, err := snsmessage.NewFromJSON(encoded)
+		if err != nil {
+			results = append(results, newResult(source, index, nil, err))
+			continue
+		}
+
+		validator := opts.verifier.Validator(message)
+		validator.CertFetcher = &onceCertFetcher{fetcher: validator.CertFetcher}
+		err = validator.ValidateMessage()
+		r := newResult(source, index, message, err)
+		if opts.explain && (err == nil || isSignatureError(err)) {
+			r.Explanation = newExplanation(validator.ExplainSignature(context.Background()))
+		}
+		results = append(results, r)
+	}
+}
+
+// isSignatureError returns whether the validation error is of the signature
+// verification, which ExplainSignature explains, and not of the message
+// structure or age
+func isSignatureError(err error) bool {
+	return errors.Is(err, snsvalidator.ErrIncorrectSignature) || errors.Is(err, snsvalidator.ErrInvalidCert)
+}
+
+// newExplanation returns the explanation of the signature verification
+func newExplanation(signatureExplanation *snsvalidator.SignatureExplanation) *explanation {
+	e := &explanation{
+		SignableString:   string(signatureExplanation.SignableString),
+		SignatureVersion: signatureExplanation.SignatureVersion,
+		Digest:           hex.EncodeToString(signatureExplanation.Digest),
+		SignatureLength:  signatureExplanation.SignatureLength,
+		FailedStep:       signatureExplanation.FailedStep,
+	}
+	if signatureExplanation.Hash != 0 {
+		e.Hash = signatureExplanation.Hash.String()
+	}
+	if cert := signatureExplanation.Certificate; cert != nil {
+		fingerprint := sha256.Sum256(cert.Raw)
+		e.Certificate = &certificate{
+			Subject:     cert.Subject.String(),
+			NotAfter:    cert.NotAfter,
+			Fingerprint: fingerprintString(fingerprint[:]),
+		}
+	}
+	return e
+}
+
+// fingerprintString returns the fingerprint as colon-separated hex bytes
+func fingerprintString(fingerprint []byte) string {
+	bytes := make([]string, len(fingerprint))
+	for i, b := range fingerprint {
+		bytes[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(bytes, ":")
+}
+
+// newResult returns the result of the message validated with the error
+func newResult(source string, index int, message *snsmessage.SNSMessage, err error) result {
+	r := result{Source: source, Index: index, Valid: err == nil, err: err}
+	if message != nil {
+		r.Type = message.Type
+		r.MessageId = message.MessageId
+	}
+	if _, ok := err.(json.Marshaler); ok {
+		r.Error = err
+	} else if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// printResult prints the result as human text
+func printResult(w io.Writer, r result) {
+	label := fmt.Sprintf("%s#%d", r.Source, r.Index)
+	if r.MessageId != "" {
+		label += fmt.Sprintf(" %s %s", r.Type, r.MessageId)
+	}
+
+	if r.Valid {
+		fmt.Fprintf(w, "%s: OK\n", label)
+	} else if snsErr, ok := r.err.(*snserrors.SNSError); ok {
+		fmt.Fprintf(w, "%s: FAIL [%s] %v\n", label, snsErr.Code(), r.err)
+	} else {
+		fmt.Fprintf(w, "%s: FAIL %v\n", label, r.err)
+	}
+
+	if r.Explanation != nil {
+		printExplanation(w, r.Explanation)
+	}
+}
+
+// printExplanation prints the explanation as human text, with the newlines of
+// the signable string shown as "\n"
+func printExplanation(w io.Writer, e *explanation) {
+	fmt.Fprintln(w, "  Signable string:")
+	for _, line := range strings.SplitAfter(e.SignableString, "\n") {
+		if line != "" {
+			fmt.Fprintf(w, "    %s\n", strings.Replace(line, "\n", `\n`, 1))
+		}
+	}
+
+	if e.Hash != "" {
+		fmt.Fprintf(w, "  Signature version: %s (%s with RSA)\n", e.SignatureVersion, e.Hash)
+		fmt.Fprintf(w, "  Digest: %s\n", e.Digest)
+	} else {
+		fmt.Fprintf(w, "  Signature version: %s (unsupported)\n", e.SignatureVersion)
+	}
+
+	if e.SignatureLength >= 0 {
+		fmt.Fprintf(w, "  Signature length: %d bytes\n", e.SignatureLength)
+	} else {
+		fmt.Fprintln(w, "  Signature length: not base64")
+	}
+
+	if e.Certificate != nil {
+		fmt.Fprintf(w, "  Certificate subject: %s\n", e.Certificate.Subject)
+		fmt.Fprintf(w, "  Certificate expiry: %s\n", e.Certificate.NotAfter.Format(time.RFC3339))
+		fmt.Fprintf(w, "  Certificate SHA256 fingerprint: %s\n", e.Certificate.Fingerprint)
+	} else {
+		fmt.Fprintln(w, "  Certificate: not retrieved")
+	}
+
+	if e.FailedStep != "" {
+		fmt.Fprintf(w, "  Failed step: %s\n", e.FailedStep)
+	} else {
+		fmt.Fprintln(w, "  Signature: correct")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Notification signed by _assets/fakecert.key
+const validMessage = `{
+  "Type": "Notification",
+  "MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+  "Token": "token-is-unnecessary-in-notification-message",
+  "TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic",
+  "Message": "Test notification",
+  "Subject": "Test subject",
+  "Timestamp": "2012-04-26T20:45:04.751Z",
+  "SignatureVersion": "1",
+  "Signature": "ol5x/KiU+7dWKRuyD6Y1EntwXo+orXlVgQbq4JDy5uh/+EBBz/mfWQ0X0LXyyxkXXCykDakEz1F0h9y9xV9UitLlYA/tEMzI7WU9ob9d9L8YTCZVaHZUtCu4S0p0eCFzT69q+ijPuH9N1znuZOzDogsJIf8E9/8owtRmi6M50Co=",
+  "SigningCertURL": "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+  "UnsubscribeURL": "https://localhost/unsubscribe"
+}`
+
+func newCertDir(t *testing.T) string {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	certDir := newCertDir(t)
+	forgedMessage := strings.Replace(validMessage, "Test notification", "Forged notification", 1)
+
+	Convey("Given valid and forged messages from stdin", t, func() {
+		stdin := strings.NewReader(validMessage + "\n" + forgedMessage)
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		Convey("It should print the result of each message and fail", func() {
+			status := run([]string{"-certDir", certDir, "-offline"}, stdin, stdout, stderr)
+
+			So(status, ShouldEqual, exitInvalid)
+			So(stdout.String(), ShouldEqual,
+				"stdin#1 Notification 165545c9-2a5c-472c-8df2-7ff2be2b3b1b: OK\n"+
+					"stdin#2 Notification 165545c9-2a5c-472c-8df2-7ff2be2b3b1b: FAIL [signature_mismatch] Incorrect signature: crypto/rsa: verification error\n")
+		})
+
+		Convey("It should print the results as JSON", func() {
+			status := run([]string{"-certDir", certDir, "-offline", "-json"}, stdin, stdout, stderr)
+
+			var results []map[string]interface{}
+			json.Unmarshal(stdout.Bytes(), &results)
+
+			So(status, ShouldEqual, exitInvalid)
+			So(results, ShouldHaveLength, 2)
+			So(results[0]["valid"], ShouldEqual, true)
+			So(results[1]["valid"], ShouldEqual, false)
+			So(results[1]["error"].(map[string]interface{})["code"], ShouldEqual, "signature_mismatch")
+		})
+	})
+
+	Convey("Given a valid message file", t, func() {
+		path := filepath.Join(t.TempDir(), "message.json")
+		os.WriteFile(path, []byte(validMessage), 0644)
+		stdout := &bytes.Buffer{}
+
+		Convey("It should succeed", func() {
+			status := run([]string{"-certDir", certDir, "-offline", path}, nil, stdout, &bytes.Buffer{})
+
+			So(status, ShouldEqual, exitValid)
+		})
+
+		Convey("It should fail with -maxAge", func() {
+			status := run([]string{"-certDir", certDir, "-offline", "-maxAge", "1h", path}, nil, stdout, &bytes.Buffer{})
+
+			So(status, ShouldEqual, exitInvalid)
+			So(stdout.String(), ShouldContainSubstring, "[expired_message]")
+		})
+
+		Convey("It should fail with -signatureVersion 2", func() {
+			status := run([]string{"-certDir", certDir, "-offline", "-signatureVersion", "2", path}, nil, stdout, &bytes.Buffer{})
+
+			So(status, ShouldEqual, exitInvalid)
+			So(stdout.String(), ShouldContainSubstring, "[unsupported_signature_version]")
+		})
+
+		Convey("It should fail with -trustedHost of another host", func() {
+			status := run([]string{"-certDir", certDir, "-offline", "-trustedHost", `^localhost$`, path}, nil, stdout, &bytes.Buffer{})
+
+			So(status, ShouldEqual, exitInvalid)
+			So(stdout.String(), ShouldContainSubstring, "[untrusted_cert_host]")
+		})
+	})
+
+	Convey("Given a forged message from stdin with -explain", t, func() {
+		stdout := &bytes.Buffer{}
+
+		Convey("It should print the signable string and the failed step", func() {
+			status := run([]string{"-certDir", certDir, "-offline", "-explain"}, strings.NewReader(forgedMessage), stdout, &bytes.Buffer{})
+
+			So(status, ShouldEqual, exitInvalid)
+			So(stdout.String(), ShouldContainSubstring, "    Message\\n\n    Forged notification\\n\n")
+			So(stdout.String(), ShouldContainSubstring, "  Signature version: 1 (SHA-1 with RSA)\n")
+			So(stdout.String(), ShouldContainSubstring, "  Signature length: 128 bytes\n")
+			So(stdout.String(), ShouldContainSubstring, "  Certificate subject: ")
+			So(stdout.String(), ShouldContainSubstring, "  Failed step: check_signature\n")
+		})
+
+		Convey("It should include the explanation in JSON", func() {
+			run([]string{"-certDir", certDir, "-offline", "-explain", "-json"}, strings.NewReader(forgedMessage), stdout, &bytes.Buffer{})
+
+			var results []result
+			json.Unmarshal(stdout.Bytes(), &results)
+
+			So(results[0].Explanation.FailedStep, ShouldEqual, "check_signature")
+			So(results[0].Explanation.Hash, ShouldEqual, "SHA-1")
+			So(results[0].Explanation.Certificate.Fingerprint, ShouldHaveLength, 95)
+		})
+	})
+
+	Convey("Given an expired message from stdin with -explain", t, func() {
+		stdout := &bytes.Buffer{}
+
+		Convey("It should not explain the signature which is not verified", func() {
+			status := run([]string{"-certDir", certDir, "-offline", "-maxAge", "1h", "-explain"}, strings.NewReader(validMessage), stdout, &bytes.Buffer{})
+
+			So(status, ShouldEqual, exitInvalid)
+			So(stdout.String(), ShouldContainSubstring, "[expired_message]")
+			So(stdout.String(), ShouldNotContainSubstring, "Signable string")
+		})
+	})
+
+	Convey("Given malformed JSON from stdin", t, func() {
+		stdout := &bytes.Buffer{}
+
+		Convey("It should report the malformed JSON", func() {
+			status := run([]string{"-certDir", certDir, "-offline"}, strings.NewReader(validMessage+"{"), stdout, &bytes.Buffer{})
+
+			So(status, ShouldEqual, exitInvalid)
+			So(stdout.String(), ShouldContainSubstring, "stdin#2: FAIL [MalformedJSON]")
+		})
+	})
+
+	Convey("Given invalid flags", t, func() {
+		Convey("It should exit with the usage status", func() {
+			So(run([]string{"-offline"}, nil, &bytes.Buffer{}, &bytes.Buffer{}), ShouldEqual, exitUsage)
+			So(run([]string{"-signatureVersion", "3"}, nil, &bytes.Buffer{}, &bytes.Buffer{}), ShouldEqual, exitUsage)
+			So(run([]string{"-trustedHost", "("}, nil, &bytes.Buffer{}, &bytes.Buffer{}), ShouldEqual, exitUsage)
+			So(run([]string{"absent.json"}, nil, &bytes.Buffer{}, &bytes.Buffer{}), ShouldEqual, exitUsage)
+		})
+	})
+}
+
+// countingCertFetcher counts the certificates retrieved and fails
+type countingCertFetcher struct {
+	count int
+}
+
+func (fetcher *countingCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	fetcher.count++
+	return nil, errors.New("Unavailable")
+}
+
+func TestOnceCertFetcher(t *testing.T) {
+	Convey("Given a onceCertFetcher", t, func() {
+		counting := &countingCertFetcher{}
+		once := &onceCertFetcher{fetcher: counting}
+
+		Convey("It should retrieve the certificate once and share the failure", func() {
+			_, first := once.FetchCertificate(context.Background(), "https://sns.ap-northeast-1.amazonaws.com/cert.pem")
+			_, second := once.FetchCertificate(context.Background(), "https://sns.ap-northeast-1.amazonaws.com/cert.pem")
+
+			So(counting.count, ShouldEqual, 1)
+			So(second, ShouldEqual, first)
+		})
+	})
+}
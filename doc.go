@@ -4,4 +4,8 @@
 // The pakcage can validates an incoming SNS Message authenticity and integrity
 // by validating the message structure and verifying the message signature. It
 // is standalone and does not require AWS SDK to work.
+//
+// Validate decodes and validates a JSON-encoded SNS message in one call. The
+// message model is in package snsmodel, which both the parser snsmessage and
+// the validator snsvalidator depend on.
 package validator
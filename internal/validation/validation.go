@@ -0,0 +1,8 @@
+// Package validation lets the packages of the module mark a SNS message as
+// validated, without exposing the ability to the users of the module.
+package validation
+
+// SetValidated sets whether the *snsmodel.Message is validated. It is
+// registered by package snsmodel on initialization, since this package cannot
+// import snsmodel which imports it.
+var SetValidated func(message interface{}, validated bool)
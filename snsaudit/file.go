@@ -0,0 +1,160 @@
+package snsaudit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DefaultMaxSize is the default size in bytes of an audit log file before it
+// is rotated
+const DefaultMaxSize = 100 << 20
+
+// DefaultMaxBackups is the default number of rotated audit log files kept
+const DefaultMaxBackups = 5
+
+// FileSink is a Sink appending the records as JSON lines to a file. When the
+// file would grow beyond MaxSize, it is rotated: the file is renamed with the
+// suffix ".1", the previous ".1" to ".2" and so on, and the oldest beyond
+// MaxBackups is removed.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+var _ Sink = (*FileSink)(nil)
+
+// FileSinkOption configures a FileSink
+type FileSinkOption func(*FileSink)
+
+// WithMaxSize sets the size in bytes of the file before it is rotated,
+// DefaultMaxSize by default
+func WithMaxSize(maxSize int64) FileSinkOption {
+	return func(sink *FileSink) {
+		sink.maxSize = maxSize
+	}
+}
+
+// WithMaxBackups sets the number of rotated files kept, DefaultMaxBackups by
+// default. At least one is kept, so that the records are never discarded on
+// rotation, and NewFileSink rejects a lower number.
+func WithMaxBackups(maxBackups int) FileSinkOption {
+	return func(sink *FileSink) {
+		sink.maxBackups = maxBackups
+	}
+}
+
+// NewFileSink opens the file of the path for appending the records, creating
+// it if it does not exist
+func NewFileSink(path string, options ...FileSinkOption) (*FileSink, error) {
+	sink := &FileSink{path: path, maxSize: DefaultMaxSize, maxBackups: DefaultMaxBackups}
+	for _, option := range options {
+		option(sink)
+	}
+	if sink.maxBackups < 1 {
+		return nil, fmt.Errorf("Audit log %s must keep at least one backup, not %d", path, sink.maxBackups)
+	}
+
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// Audit appends the record as a JSON line to the file, rotating the file
+// first if it would grow beyond the maximum size. If the rotation fails, the
+// record is still appended to the current file and the error of the rotation
+// is returned, so that the audit trail goes on.
+func (sink *FileSink) Audit(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.file == nil {
+		return fmt.Errorf("Audit log %s is closed", sink.path)
+	}
+
+	var rotateErr error
+	if sink.size > 0 && sink.size+int64(len(line)) > sink.maxSize {
+		rotateErr = sink.rotate()
+	}
+
+	n, err := sink.file.Write(line)
+	sink.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// Close closes the file. The records audited afterwards are rejected.
+func (sink *FileSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.file == nil {
+		return nil
+	}
+	err := sink.file.Close()
+	sink.file = nil
+	return err
+}
+
+// open opens the file for appending and records its current size
+func (sink *FileSink) open() error {
+	file, err := os.OpenFile(sink.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	sink.file = file
+	sink.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups, renames the file to the first backup and opens a
+// new file. If any step fails, the current file is kept open, so that the
+// records are still appended to it.
+func (sink *FileSink) rotate() error {
+	os.Remove(sink.backup(sink.maxBackups))
+	for i := sink.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(sink.backup(i), sink.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Could not rotate audit log %s: %w", sink.path, err)
+		}
+	}
+	if err := os.Rename(sink.path, sink.backup(1)); err != nil {
+		return fmt.Errorf("Could not rotate audit log %s: %w", sink.path, err)
+	}
+
+	previous := sink.file
+	if err := sink.open(); err != nil {
+		// Put the file back in place, it is still open
+		os.Rename(sink.backup(1), sink.path)
+		return fmt.Errorf("Could not rotate audit log %s: %w", sink.path, err)
+	}
+	previous.Close()
+	return nil
+}
+
+// backup returns the path of the i-th rotated file
+func (sink *FileSink) backup(i int) string {
+	return fmt.Sprintf("%s.%d", sink.path, i)
+}
@@ -0,0 +1,118 @@
+package snsaudit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// readRecords returns the records in the JSON-lines file
+func readRecords(path string) []Record {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		json.Unmarshal(scanner.Bytes(), &record)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestFileSink(t *testing.T) {
+	Convey("Given a FileSink", t, func() {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		line, _ := json.Marshal(Record{MessageId: "1"})
+		sink, err := NewFileSink(path, WithMaxSize(int64(len(line)+1)*2), WithMaxBackups(2))
+		So(err, ShouldBeNil)
+		defer sink.Close()
+
+		Convey("It should append the records as JSON lines", func() {
+			So(sink.Audit(context.Background(), Record{MessageId: "1"}), ShouldBeNil)
+			So(sink.Audit(context.Background(), Record{MessageId: "2"}), ShouldBeNil)
+
+			records := readRecords(path)
+			So(len(records), ShouldEqual, 2)
+			So(records[0].MessageId, ShouldEqual, "1")
+			So(records[1].MessageId, ShouldEqual, "2")
+		})
+
+		Convey("It should rotate the file by size and keep the backups", func() {
+			for _, id := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+				So(sink.Audit(context.Background(), Record{MessageId: id}), ShouldBeNil)
+			}
+
+			So(readRecords(path)[0].MessageId, ShouldEqual, "7")
+			So(readRecords(path + ".1")[0].MessageId, ShouldEqual, "5")
+			So(readRecords(path + ".2")[0].MessageId, ShouldEqual, "3")
+			So(readRecords(path+".3"), ShouldBeNil)
+		})
+
+		Convey("When the file cannot be rotated", func() {
+			// A file cannot be renamed over a non-empty directory
+			os.MkdirAll(filepath.Join(path+".2", "busy"), 0700)
+			os.MkdirAll(filepath.Join(path+".1", "busy"), 0700)
+			for _, id := range []string{"1", "2"} {
+				So(sink.Audit(context.Background(), Record{MessageId: id}), ShouldBeNil)
+			}
+			err := sink.Audit(context.Background(), Record{MessageId: "3"})
+
+			Convey("It should report the error and keep appending to the file", func() {
+				So(err, ShouldNotBeNil)
+				So(len(readRecords(path)), ShouldEqual, 3)
+
+				So(sink.Audit(context.Background(), Record{MessageId: "4"}), ShouldNotBeNil)
+				So(len(readRecords(path)), ShouldEqual, 4)
+			})
+
+			Convey("It should rotate the file once the error is gone", func() {
+				os.RemoveAll(path + ".1")
+				os.RemoveAll(path + ".2")
+
+				So(sink.Audit(context.Background(), Record{MessageId: "4"}), ShouldBeNil)
+				So(len(readRecords(path)), ShouldEqual, 1)
+				So(len(readRecords(path+".1")), ShouldEqual, 3)
+			})
+		})
+
+		Convey("It should append to the existing file when reopened", func() {
+			So(sink.Audit(context.Background(), Record{MessageId: "1"}), ShouldBeNil)
+			So(sink.Close(), ShouldBeNil)
+
+			reopened, err := NewFileSink(path)
+			So(err, ShouldBeNil)
+			defer reopened.Close()
+			So(reopened.Audit(context.Background(), Record{MessageId: "2"}), ShouldBeNil)
+
+			So(len(readRecords(path)), ShouldEqual, 2)
+		})
+
+		Convey("It should reject the records after it is closed", func() {
+			So(sink.Close(), ShouldBeNil)
+
+			So(sink.Audit(context.Background(), Record{}), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a FileSink without backups", t, func() {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		sink, err := NewFileSink(path, WithMaxBackups(0))
+
+		Convey("It should be rejected, so that rotating does not discard the records", func() {
+			So(sink, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+			_, statErr := os.Stat(path)
+			So(os.IsNotExist(statErr), ShouldBeTrue)
+		})
+	})
+}
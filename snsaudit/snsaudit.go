@@ -0,0 +1,170 @@
+// Package snsaudit records an audit log of the validated SNS messages for
+// forensics. A Record describes the outcome of a validation, with the typed
+// error of the rejection, the source of the message and a hash of its body.
+// The body itself is never recorded.
+package snsaudit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"math"
+	"time"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Record is an audit record of a validated SNS message
+type Record struct {
+	// Time is when the message is validated
+	Time time.Time `json:"time"`
+	// Accepted is whether the message passed the validation
+	Accepted bool `json:"accepted"`
+	// Reason is the type of the error rejecting the message, see
+	// snserrors.Outcome
+	Reason string `json:"reason,omitempty"`
+	// Code is the machine-readable code of the error rejecting the message
+	Code string `json:"code,omitempty"`
+	// Error is the message of the error rejecting the message
+	Error string `json:"error,omitempty"`
+	// Temporary is whether the rejection is temporary, see
+	// snserrors.IsTemporary
+	Temporary bool `json:"temporary,omitempty"`
+	// Source is where the message comes from, e.g. the remote address of the
+	// HTTP request
+	Source string `json:"source,omitempty"`
+
+	MessageId   string `json:"messageId,omitempty"`
+	TopicArn    string `json:"topicArn,omitempty"`
+	MessageType string `json:"messageType,omitempty"`
+	CertURL     string `json:"certUrl,omitempty"`
+
+	// BodySHA256 is the hex-encoded SHA256 hash of the message body
+	BodySHA256 string `json:"bodySha256,omitempty"`
+	// BodySize is the size of the message body in bytes
+	BodySize int64 `json:"bodySize"`
+	// BodyTruncated is whether the body is longer than the read limit, in
+	// which case BodySHA256 and BodySize cover only the body up to the limit
+	BodyTruncated bool `json:"bodyTruncated,omitempty"`
+}
+
+// NewRecord returns the Record of the message validated with the error, nil
+// if the message is accepted. The message may be nil if it could not be
+// decoded.
+func NewRecord(message snsvalidator.Message, err error) Record {
+	record := Record{Time: time.Now().UTC(), Accepted: err == nil}
+	if err != nil {
+		record.Reason = snserrors.Outcome(err)
+		record.Error = err.Error()
+		record.Temporary = snserrors.IsTemporary(err)
+
+		var snsErr *snserrors.SNSError
+		if errors.As(err, &snsErr) {
+			record.Code = snsErr.Code()
+		}
+	}
+
+	if message != nil {
+		record.MessageId, _ = message.Field("MessageId")
+		record.TopicArn, _ = message.Field("TopicArn")
+		record.MessageType, _ = message.Field("Type")
+		record.CertURL, _ = message.Field("SigningCertURL")
+	}
+	return record
+}
+
+// Sink stores the audit records. It must be safe for concurrent use.
+type Sink interface {
+	Audit(ctx context.Context, record Record) error
+}
+
+// Auditor records the rejected SNS messages, and optionally the accepted
+// ones, to the Sink
+type Auditor struct {
+	Sink Sink
+	// IncludeAccepted records the accepted messages as well
+	IncludeAccepted bool
+	// OnError is called with the error if the Sink fails to store a record.
+	// If it is nil, the error is discarded
+	OnError func(err error)
+}
+
+// Audit stores the record to the Sink, unless it is accepted and accepted
+// messages are not included
+func (auditor *Auditor) Audit(ctx context.Context, record Record) {
+	if record.Accepted && !auditor.IncludeAccepted {
+		return
+	}
+
+	if err := auditor.Sink.Audit(ctx, record); err != nil && auditor.OnError != nil {
+		auditor.OnError(err)
+	}
+}
+
+// BodyReader hashes the body read through it, so that the body is hashed
+// while it is decoded without buffering it. Only the first limit bytes of the
+// body are hashed, even if more is read through it.
+type BodyReader struct {
+	reader    io.Reader
+	hash      hash.Hash
+	limit     int64
+	size      int64
+	truncated bool
+}
+
+// NewBodyReader returns a BodyReader reading the body from the reader and
+// hashing up to limit bytes of it. A non-positive limit hashes the whole body.
+func NewBodyReader(reader io.Reader, limit int64) *BodyReader {
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	return &BodyReader{reader: reader, hash: sha256.New(), limit: limit}
+}
+
+func (body *BodyReader) Read(p []byte) (int, error) {
+	n, err := body.reader.Read(p)
+
+	hashed := int64(n)
+	if remaining := body.limit - body.size; hashed > remaining {
+		hashed = remaining
+		body.truncated = true
+	}
+	body.hash.Write(p[:hashed])
+	body.size += hashed
+	return n, err
+}
+
+// Drain reads the rest of the body through the hash, up to exactly the limit.
+// The decoding of a malformed or oversized body stops early, and the hash must
+// cover the whole body regardless. If the body is longer than the limit, the
+// rest is not hashed and the body is flagged truncated.
+func (body *BodyReader) Drain() error {
+	if !body.truncated && body.size < body.limit {
+		if _, err := io.Copy(io.Discard, io.LimitReader(body, body.limit-body.size)); err != nil {
+			return err
+		}
+	}
+	if body.truncated {
+		return nil
+	}
+
+	// Probe the byte past the limit without hashing it
+	var probe [1]byte
+	n, err := io.ReadFull(body.reader, probe[:])
+	body.truncated = n > 0
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// Fill sets the hash and the size of the body read so far to the record
+func (body *BodyReader) Fill(record *Record) {
+	record.BodySHA256 = hex.EncodeToString(body.hash.Sum(nil))
+	record.BodySize = body.size
+	record.BodyTruncated = body.truncated
+}
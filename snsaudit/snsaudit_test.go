@@ -0,0 +1,193 @@
+package snsaudit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// memorySink records the audit records in memory
+type memorySink struct {
+	mu      sync.Mutex
+	records []Record
+	err     error
+}
+
+func (sink *memorySink) Audit(ctx context.Context, record Record) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.records = append(sink.records, record)
+	return sink.err
+}
+
+// fieldMessage is a snsvalidator.Message of the fields in the map
+type fieldMessage map[string]string
+
+func (message fieldMessage) Field(key string) (string, bool) {
+	value, ok := message[key]
+	return value, ok
+}
+
+func TestNewRecord(t *testing.T) {
+	message := fieldMessage{
+		"Type":           "Notification",
+		"MessageId":      "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		"TopicArn":       "arn:aws:sns:us-west-2:123456789012:MyTopic",
+		"SigningCertURL": "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+	}
+
+	Convey("Given a message rejected with a typed error", t, func() {
+		err := snsvalidator.ErrIncorrectSignature.WithCode("signature_mismatch")
+
+		Convey("It should record the reason of the rejection and the message", func() {
+			record := NewRecord(message, err)
+
+			So(record.Accepted, ShouldBeFalse)
+			So(record.Reason, ShouldEqual, snsvalidator.ErrTypeIncorrectSignature)
+			So(record.Code, ShouldEqual, err.Code())
+			So(record.Error, ShouldEqual, err.Error())
+			So(record.Temporary, ShouldBeFalse)
+			So(record.MessageId, ShouldEqual, message["MessageId"])
+			So(record.TopicArn, ShouldEqual, message["TopicArn"])
+			So(record.MessageType, ShouldEqual, message["Type"])
+			So(record.CertURL, ShouldEqual, message["SigningCertURL"])
+			So(record.Time.IsZero(), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a message rejected with a temporary error", t, func() {
+		err := snsvalidator.ErrInvalidCert.WithTemporary(true)
+
+		Convey("It should record the rejection as temporary", func() {
+			So(NewRecord(message, err).Temporary, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a message which could not be decoded", t, func() {
+		err := errors.New("unexpected EOF")
+
+		Convey("It should record the rejection without the message", func() {
+			record := NewRecord(nil, err)
+
+			So(record.Reason, ShouldEqual, snserrors.OutcomeUnknown)
+			So(record.Code, ShouldBeEmpty)
+			So(record.MessageId, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given an accepted message", t, func() {
+		Convey("It should record the acceptance without reason", func() {
+			record := NewRecord(message, nil)
+
+			So(record.Accepted, ShouldBeTrue)
+			So(record.Reason, ShouldBeEmpty)
+			So(record.Error, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestAuditor(t *testing.T) {
+	Convey("Given an Auditor", t, func() {
+		sink := &memorySink{}
+		auditor := &Auditor{Sink: sink}
+
+		Convey("It should record the rejections only", func() {
+			auditor.Audit(context.Background(), Record{Accepted: true})
+			auditor.Audit(context.Background(), Record{Reason: snsvalidator.ErrTypeIncorrectSignature})
+
+			So(len(sink.records), ShouldEqual, 1)
+			So(sink.records[0].Accepted, ShouldBeFalse)
+		})
+
+		Convey("It should record the acceptances if included", func() {
+			auditor.IncludeAccepted = true
+			auditor.Audit(context.Background(), Record{Accepted: true})
+
+			So(len(sink.records), ShouldEqual, 1)
+		})
+
+		Convey("It should report the errors of the Sink", func() {
+			var reported error
+			sink.err = errors.New("Disk full")
+			auditor.OnError = func(err error) { reported = err }
+			auditor.Audit(context.Background(), Record{})
+
+			So(reported, ShouldEqual, sink.err)
+		})
+	})
+}
+
+func TestBodyReader(t *testing.T) {
+	Convey("Given a body read through a BodyReader", t, func() {
+		body := `{"Type":"Notification"}`
+		reader := NewBodyReader(strings.NewReader(body), 0)
+		read, _ := io.ReadAll(reader)
+
+		Convey("It should pass the body through and record its hash and size", func() {
+			var record Record
+			reader.Fill(&record)
+			sum := sha256.Sum256([]byte(body))
+
+			So(string(read), ShouldEqual, body)
+			So(record.BodySHA256, ShouldEqual, hex.EncodeToString(sum[:]))
+			So(record.BodySize, ShouldEqual, len(body))
+		})
+	})
+
+	Convey("Given a body read partially through a BodyReader", t, func() {
+		body := `{"Type":"Notification",` + strings.Repeat(" ", 1000) + `}`
+
+		Convey("It should hash the whole body once drained", func() {
+			reader := NewBodyReader(strings.NewReader(body), int64(len(body)))
+			reader.Read(make([]byte, 10))
+			So(reader.Drain(), ShouldBeNil)
+
+			var record Record
+			reader.Fill(&record)
+			sum := sha256.Sum256([]byte(body))
+
+			So(record.BodySHA256, ShouldEqual, hex.EncodeToString(sum[:]))
+			So(record.BodySize, ShouldEqual, len(body))
+			So(record.BodyTruncated, ShouldBeFalse)
+		})
+
+		Convey("It should hash the body up to the limit and flag it truncated", func() {
+			reader := NewBodyReader(strings.NewReader(body), 100)
+			reader.Read(make([]byte, 10))
+			So(reader.Drain(), ShouldBeNil)
+
+			var record Record
+			reader.Fill(&record)
+			sum := sha256.Sum256([]byte(body[:100]))
+
+			So(record.BodySHA256, ShouldEqual, hex.EncodeToString(sum[:]))
+			So(record.BodySize, ShouldEqual, 100)
+			So(record.BodyTruncated, ShouldBeTrue)
+		})
+
+		Convey("It should not hash the bytes read past the limit", func() {
+			reader := NewBodyReader(strings.NewReader(body), 100)
+			n, _ := io.ReadFull(reader, make([]byte, 101))
+			So(n, ShouldEqual, 101)
+			So(reader.Drain(), ShouldBeNil)
+
+			var record Record
+			reader.Fill(&record)
+			sum := sha256.Sum256([]byte(body[:100]))
+
+			So(record.BodySHA256, ShouldEqual, hex.EncodeToString(sum[:]))
+			So(record.BodySize, ShouldEqual, 100)
+			So(record.BodyTruncated, ShouldBeTrue)
+		})
+	})
+}
@@ -1,16 +1,76 @@
 // Package snserrors is a custom error with type and message. It provides
 // functions to manipulate errors.
+//
+// SNSError works with errors.Is and errors.As. An SNSError matches any other
+// SNSError of the same type with errors.Is, so packages can declare one
+// sentinel SNSError per type for callers to compare with. The underlying
+// error, if any, is available through errors.Unwrap.
+//
+// An SNSError can be marked as temporary, meaning the same input may succeed
+// if it is retried later. IsTemporary classifies any error for retry
+// decisions.
 package snserrors
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
 // snsError is an private structure to record an SNS error
 type SNSError struct {
-	t string // Type
-	s string // Message
+	t       string  // Type
+	s       string  // Message
+	cause   error   // Underlying error
+	code    string  // Machine-readable code
+	details Details // Structured details
+
+	temporary bool // Whether retrying may succeed
+}
+
+// Details records the structured details of an SNS error. Only the details
+// relevant to the error are set, the others are left with zero value
+type Details struct {
+	Key         string // The offending key of the SNS message
+	MessageType string // The type of the SNS message
+	CertURL     string // The signing certificate URL
+	StatusCode  int    // The HTTP status code of retrieving the certificate
 }
 
 // Create and return an error with given type and message
 func New(errType string, errMsg string) *SNSError {
-	return &SNSError{errType, errMsg}
+	return &SNSError{t: errType, s: errMsg}
+}
+
+// Create and return an error with given type and message wrapping the
+// underlying cause. If the message is empty, the message of the cause is used
+func Wrap(errType string, cause error, errMsg string) *SNSError {
+	if errMsg == "" && cause != nil {
+		errMsg = cause.Error()
+	}
+	return &SNSError{t: errType, s: errMsg, cause: cause}
+}
+
+// Return a copy of the error with the given machine-readable code
+func (err *SNSError) WithCode(code string) *SNSError {
+	copied := *err
+	copied.code = code
+	return &copied
+}
+
+// Return a copy of the error with the given structured details
+func (err *SNSError) WithDetails(details Details) *SNSError {
+	copied := *err
+	copied.details = details
+	return &copied
+}
+
+// Return a copy of the error marked as temporary or not
+func (err *SNSError) WithTemporary(temporary bool) *SNSError {
+	copied := *err
+	copied.temporary = temporary
+	return &copied
 }
 
 // Return the type of the error
@@ -18,14 +78,201 @@ func (err *SNSError) Type() string {
 	return err.t
 }
 
+// Return the machine-readable code of the error. A code tells apart errors of
+// the same type, e.g. an untrusted certificate host from an unreachable
+// certificate URL. If the error has no code, its type is returned
+func (err *SNSError) Code() string {
+	if err.code == "" {
+		return err.t
+	}
+	return err.code
+}
+
+// Return the structured details of the error
+func (err *SNSError) Details() Details {
+	return err.details
+}
+
+// Determine if the error is marked as temporary. Use IsTemporary to also
+// take the underlying cause into account
+func (err *SNSError) Temporary() bool {
+	return err.temporary
+}
+
 // Determine if an error is the given type.
 // Returns true if the error is exactly the given type, false otherwise
-func (err *SNSError) Is(errType string) bool {
+func (err *SNSError) IsType(errType string) bool {
 	return err.t == errType
 }
 
+// Determine if an error matches the target for errors.Is.
+// Returns true if the target is an SNSError of the same type, false otherwise
+func (err *SNSError) Is(target error) bool {
+	targetErr, ok := target.(*SNSError)
+	return ok && err.t == targetErr.t
+}
+
+// Return the underlying cause of the error, or nil if there is none
+func (err *SNSError) Unwrap() error {
+	return err.cause
+}
+
 // Return the message of the error. This method also control how fmt package
 // formats the error value
 func (err *SNSError) Error() string {
 	return err.s
 }
+
+// jsonError is the JSON representation of SNSError
+type jsonError struct {
+	Type        string `json:"type"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Key         string `json:"key,omitempty"`
+	MessageType string `json:"messageType,omitempty"`
+	CertURL     string `json:"certUrl,omitempty"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	Temporary   bool   `json:"temporary,omitempty"`
+}
+
+// Return the JSON encoding of the error with its type, code, message, the
+// non-empty details and whether it is temporary. The underlying cause is not
+// included
+func (err *SNSError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonError{
+		Type:        err.t,
+		Code:        err.Code(),
+		Message:     err.s,
+		Key:         err.details.Key,
+		MessageType: err.details.MessageType,
+		CertURL:     err.details.CertURL,
+		StatusCode:  err.details.StatusCode,
+		Temporary:   IsTemporary(err),
+	})
+}
+
+// Determine if any error in the chain of err is an SNSError of the given type
+func HasType(err error, errType string) bool {
+	return errors.Is(err, &SNSError{t: errType})
+}
+
+// Determine if retrying may succeed after the error. An error is temporary if
+// any error in its chain is
+//   - an SNSError marked as temporary
+//   - a context cancellation or deadline
+//   - a timeout, such as a net.Error whose Timeout() returns true
+//
+// A MultiError is temporary only if all of its errors are temporary. Any other
+// error, including nil, is permanent
+func IsTemporary(err error) bool {
+	var multiErr *MultiError
+	if errors.As(err, &multiErr) {
+		// The errors wrapping the MultiError, such as an SNSError marked as
+		// temporary, take precedence over it
+		for ; err != nil && err != error(multiErr); err = errors.Unwrap(err) {
+			if isTemporary(err) {
+				return true
+			}
+		}
+		for _, e := range multiErr.errs {
+			if !IsTemporary(e) {
+				return false
+			}
+		}
+		return len(multiErr.errs) > 0
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if isTemporary(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine if the error itself, regardless of its chain, is an SNSError
+// marked as temporary or a timeout
+func isTemporary(err error) bool {
+	if snsErr, ok := err.(*SNSError); ok && snsErr.temporary {
+		return true
+	}
+	timeoutErr, ok := err.(interface{ Timeout() bool })
+	return ok && timeoutErr.Timeout()
+}
+
+// Outcomes returned by Outcome other than the types of SNSError
+const (
+	OutcomeValid   = "Valid"
+	OutcomeUnknown = "Unknown"
+)
+
+// Return the outcome of an operation with the error for metrics and logs. The
+// outcome is OutcomeValid if err is nil, the type of the first SNSError in
+// the chain of err, or OutcomeUnknown if there is no SNSError
+func Outcome(err error) string {
+	if err == nil {
+		return OutcomeValid
+	}
+
+	var snsErr *SNSError
+	if errors.As(err, &snsErr) {
+		return snsErr.Type()
+	}
+	return OutcomeUnknown
+}
+
+// MultiError records a list of errors reported at once
+type MultiError struct {
+	errs []error
+}
+
+// Create and return a MultiError of the given errors, nil errors are
+// discarded. If all the errors are nil, nil is returned
+func Join(errs ...error) error {
+	multiErr := &MultiError{}
+	for _, err := range errs {
+		if err != nil {
+			multiErr.errs = append(multiErr.errs, err)
+		}
+	}
+	if len(multiErr.errs) == 0 {
+		return nil
+	}
+	return multiErr
+}
+
+// Return the list of errors
+func (err *MultiError) Errors() []error {
+	return err.errs
+}
+
+// Return the list of errors for errors.Is and errors.As to match any of them
+func (err *MultiError) Unwrap() []error {
+	return err.errs
+}
+
+// Return the messages of the errors separated by "; "
+func (err *MultiError) Error() string {
+	messages := make([]string, len(err.errs))
+	for i, e := range err.errs {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Return the JSON encoding of the errors as an array. Errors other than
+// SNSError are encoded with their message only
+func (err *MultiError) MarshalJSON() ([]byte, error) {
+	encoded := make([]interface{}, len(err.errs))
+	for i, e := range err.errs {
+		if _, ok := e.(json.Marshaler); ok {
+			encoded[i] = e
+		} else {
+			encoded[i] = jsonError{Message: e.Error()}
+		}
+	}
+	return json.Marshal(encoded)
+}
@@ -1,7 +1,11 @@
 package snserrors
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -9,7 +13,7 @@ import (
 
 func TestNew(t *testing.T) {
 	Convey("It should return an SNS Error with type and message", t, func() {
-		expected := SNSError{"TestError", "This is a test error"}
+		expected := SNSError{t: "TestError", s: "This is a test error"}
 		actual := New("TestError", "This is a test error")
 
 		So(*actual, ShouldResemble, expected)
@@ -28,6 +32,111 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestWrap(t *testing.T) {
+	Convey("Given an underlying cause", t, func() {
+		cause := io.ErrUnexpectedEOF
+
+		Convey("It should return an SNS Error with type, message and the cause", func() {
+			expected := SNSError{t: "TestError", s: "This is a test error", cause: cause}
+			actual := Wrap("TestError", cause, "This is a test error")
+
+			So(*actual, ShouldResemble, expected)
+		})
+
+		Convey("It should use the message of the cause when the message is empty", func() {
+			actual := Wrap("TestError", cause, "")
+
+			So(actual.Error(), ShouldEqual, "unexpected EOF")
+		})
+	})
+}
+
+func TestWithCodeMethod(t *testing.T) {
+	Convey("Given an SNS Error", t, func() {
+		err := New("TestError", "This is a test error")
+
+		Convey("It should return a copy of the error with the code", func() {
+			actual := err.WithCode("test_code")
+
+			So(actual.Code(), ShouldEqual, "test_code")
+			So(actual.Type(), ShouldEqual, "TestError")
+			So(actual.Error(), ShouldEqual, "This is a test error")
+			So(err.Code(), ShouldEqual, "TestError")
+		})
+	})
+}
+
+func TestWithDetailsMethod(t *testing.T) {
+	Convey("Given an SNS Error", t, func() {
+		err := New("TestError", "This is a test error")
+		details := Details{Key: "SigningCertURL", CertURL: "https://localhost/cert.pem", StatusCode: 404}
+
+		Convey("It should return a copy of the error with the details", func() {
+			actual := err.WithDetails(details)
+
+			So(actual.Details(), ShouldResemble, details)
+			So(err.Details(), ShouldResemble, Details{})
+		})
+	})
+}
+
+func TestWithTemporaryMethod(t *testing.T) {
+	Convey("Given an SNS Error", t, func() {
+		err := New("TestError", "This is a test error")
+
+		Convey("It should return a copy of the error marked as temporary", func() {
+			actual := err.WithTemporary(true)
+
+			So(actual.Temporary(), ShouldBeTrue)
+			So(err.Temporary(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestCodeMethod(t *testing.T) {
+	Convey("Given an SNS Error without code", t, func() {
+		err := New("TestError", "This is a test error")
+
+		Convey("It should return the type as code", func() {
+			So(err.Code(), ShouldEqual, "TestError")
+		})
+	})
+}
+
+func TestMarshalJSONMethod(t *testing.T) {
+	Convey("Given an SNS Error with code and details", t, func() {
+		err := Wrap("TestError", io.EOF, "This is a test error").
+			WithCode("test_code").
+			WithDetails(Details{Key: "SigningCertURL", CertURL: "https://localhost/cert.pem", StatusCode: 404})
+
+		Convey("It should encode the type, code, message and details", func() {
+			actual, _ := json.Marshal(err)
+
+			So(string(actual), ShouldEqual, `{"type":"TestError","code":"test_code","message":"This is a test error","key":"SigningCertURL","certUrl":"https://localhost/cert.pem","statusCode":404}`)
+		})
+	})
+
+	Convey("Given an SNS Error without code and details", t, func() {
+		err := New("TestError", "This is a test error")
+
+		Convey("It should encode the type, code and message only", func() {
+			actual, _ := json.Marshal(err)
+
+			So(string(actual), ShouldEqual, `{"type":"TestError","code":"TestError","message":"This is a test error"}`)
+		})
+	})
+
+	Convey("Given a temporary SNS Error", t, func() {
+		err := New("TestError", "This is a test error").WithTemporary(true)
+
+		Convey("It should encode the error as temporary", func() {
+			actual, _ := json.Marshal(err)
+
+			So(string(actual), ShouldEqual, `{"type":"TestError","code":"TestError","message":"This is a test error","temporary":true}`)
+		})
+	})
+}
+
 func TestTypeMethod(t *testing.T) {
 	Convey("It should return the type in string", t, func() {
 		err := New("TestError", "This is a test error")
@@ -38,17 +147,71 @@ func TestTypeMethod(t *testing.T) {
 	})
 }
 
-func TestIsMethod(t *testing.T) {
+func TestIsTypeMethod(t *testing.T) {
 	Convey("It should return true when the error is the given type", t, func() {
 		err := New("TestError", "This is a test error")
 
-		So(err.Is("TestError"), ShouldBeTrue)
+		So(err.IsType("TestError"), ShouldBeTrue)
 	})
 
 	Convey("It should return true when the error is not the given type", t, func() {
 		err := New("TestError", "This is a test error")
 
-		So(err.Is("CustomError"), ShouldBeFalse)
+		So(err.IsType("CustomError"), ShouldBeFalse)
+	})
+}
+
+func TestIsMethod(t *testing.T) {
+	sentinel := New("TestError", "Test error")
+
+	Convey("Given an SNS Error of the same type as the target", t, func() {
+		err := New("TestError", "This is a test error")
+
+		Convey("It should match the target with errors.Is", func() {
+			So(errors.Is(err, sentinel), ShouldBeTrue)
+		})
+
+		Convey("It should match the target with errors.Is when wrapped", func() {
+			So(errors.Is(fmt.Errorf("wrapped: %w", err), sentinel), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an SNS Error of different type from the target", t, func() {
+		err := New("CustomError", "This is a test error")
+
+		Convey("It should not match the target with errors.Is", func() {
+			So(errors.Is(err, sentinel), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a target which is not an SNS Error", t, func() {
+		err := New("TestError", "This is a test error")
+
+		Convey("It should not match the target", func() {
+			So(err.Is(io.EOF), ShouldBeFalse)
+		})
+	})
+}
+
+func TestUnwrapMethod(t *testing.T) {
+	Convey("Given an SNS Error wrapping a cause", t, func() {
+		err := Wrap("TestError", io.ErrUnexpectedEOF, "This is a test error")
+
+		Convey("It should return the cause", func() {
+			So(err.Unwrap(), ShouldEqual, io.ErrUnexpectedEOF)
+		})
+
+		Convey("It should match the cause with errors.Is", func() {
+			So(errors.Is(err, io.ErrUnexpectedEOF), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an SNS Error without cause", t, func() {
+		err := New("TestError", "This is a test error")
+
+		Convey("It should return nil", func() {
+			So(err.Unwrap(), ShouldBeNil)
+		})
 	})
 }
 
@@ -62,6 +225,168 @@ func TestError(t *testing.T) {
 	})
 }
 
+func TestHasType(t *testing.T) {
+	Convey("Given an error chain with an SNS Error", t, func() {
+		err := fmt.Errorf("wrapped: %w", New("TestError", "This is a test error"))
+
+		Convey("It should return true for the type of the SNS Error", func() {
+			So(HasType(err, "TestError"), ShouldBeTrue)
+		})
+
+		Convey("It should return false for other types", func() {
+			So(HasType(err, "CustomError"), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given an error which is not an SNS Error", t, func() {
+		Convey("It should return false", func() {
+			So(HasType(io.EOF, "TestError"), ShouldBeFalse)
+		})
+	})
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "i/o timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestIsTemporary(t *testing.T) {
+	Convey("Given an SNS Error marked as temporary", t, func() {
+		err := New("TestError", "This is a test error").WithTemporary(true)
+
+		Convey("It should be temporary", func() {
+			So(IsTemporary(err), ShouldBeTrue)
+		})
+
+		Convey("It should be temporary when wrapped", func() {
+			So(IsTemporary(fmt.Errorf("wrapped: %w", err)), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an SNS Error wrapping a context error", t, func() {
+		Convey("It should be temporary", func() {
+			So(IsTemporary(Wrap("TestError", context.Canceled, "")), ShouldBeTrue)
+			So(IsTemporary(Wrap("TestError", context.DeadlineExceeded, "")), ShouldBeTrue)
+		})
+
+		Convey("It should be temporary when wrapped", func() {
+			So(IsTemporary(fmt.Errorf("wrapped: %w", context.Canceled)), ShouldBeTrue)
+			So(IsTemporary(fmt.Errorf("request: %w", fmt.Errorf("fetch: %w", context.DeadlineExceeded))), ShouldBeTrue)
+			So(IsTemporary(errors.Join(io.EOF, context.Canceled)), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an SNS Error wrapping a timeout error", t, func() {
+		Convey("It should be temporary", func() {
+			So(IsTemporary(Wrap("TestError", timeoutError{}, "")), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a permanent error", t, func() {
+		Convey("It should not be temporary", func() {
+			So(IsTemporary(New("TestError", "This is a test error")), ShouldBeFalse)
+			So(IsTemporary(Wrap("TestError", io.EOF, "")), ShouldBeFalse)
+			So(IsTemporary(nil), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a MultiError", t, func() {
+		temporary := New("TestError", "This is a test error").WithTemporary(true)
+		permanent := New("CustomError", "This is a custom error")
+
+		Convey("It should be temporary only if all errors are temporary", func() {
+			So(IsTemporary(Join(temporary, context.Canceled)), ShouldBeTrue)
+			So(IsTemporary(Join(temporary, permanent)), ShouldBeFalse)
+		})
+
+		Convey("It should be checked when wrapped", func() {
+			So(IsTemporary(fmt.Errorf("wrapped: %w", Join(temporary, context.Canceled))), ShouldBeTrue)
+			So(IsTemporary(fmt.Errorf("wrapped: %w", Join(temporary, permanent))), ShouldBeFalse)
+			So(IsTemporary(Wrap("TestError", Join(temporary, context.Canceled), "")), ShouldBeTrue)
+			So(IsTemporary(Wrap("TestError", Join(temporary, permanent), "")), ShouldBeFalse)
+		})
+
+		Convey("It should be temporary when wrapped by a temporary error", func() {
+			So(IsTemporary(Wrap("TestError", Join(temporary, permanent), "").WithTemporary(true)), ShouldBeTrue)
+		})
+	})
+}
+
+func TestOutcome(t *testing.T) {
+	Convey("Given no error", t, func() {
+		Convey("It should return the valid outcome", func() {
+			So(Outcome(nil), ShouldEqual, OutcomeValid)
+		})
+	})
+
+	Convey("Given an error chain with an SNS Error", t, func() {
+		err := fmt.Errorf("wrapped: %w", New("TestError", "This is a test error"))
+
+		Convey("It should return the type of the SNS Error", func() {
+			So(Outcome(err), ShouldEqual, "TestError")
+		})
+	})
+
+	Convey("Given a MultiError", t, func() {
+		err := Join(io.EOF, New("TestError", "This is a test error"), New("CustomError", "This is a custom error"))
+
+		Convey("It should return the type of the first SNS Error", func() {
+			So(Outcome(err), ShouldEqual, "TestError")
+		})
+	})
+
+	Convey("Given an error which is not an SNS Error", t, func() {
+		Convey("It should return the unknown outcome", func() {
+			So(Outcome(io.EOF), ShouldEqual, OutcomeUnknown)
+		})
+	})
+}
+
+func TestJoin(t *testing.T) {
+	Convey("Given errors with nil errors among them", t, func() {
+		first := New("TestError", "This is a test error")
+		second := New("CustomError", "This is a custom error")
+
+		Convey("It should return a MultiError of the non-nil errors", func() {
+			err := Join(nil, first, nil, second)
+
+			So(err.(*MultiError).Errors(), ShouldResemble, []error{first, second})
+		})
+	})
+
+	Convey("Given only nil errors", t, func() {
+		Convey("It should return nil", func() {
+			So(Join(nil, nil) == nil, ShouldBeTrue)
+			So(Join() == nil, ShouldBeTrue)
+		})
+	})
+}
+
+func TestMultiError(t *testing.T) {
+	Convey("Given a MultiError", t, func() {
+		err := Join(
+			New("TestError", "This is a test error").WithCode("test_code"),
+			io.EOF,
+		)
+
+		Convey("It should return the messages separated by semicolons", func() {
+			So(err.Error(), ShouldEqual, "This is a test error; EOF")
+		})
+
+		Convey("It should match any of the errors with errors.Is", func() {
+			So(errors.Is(err, New("TestError", "")), ShouldBeTrue)
+			So(errors.Is(err, io.EOF), ShouldBeTrue)
+			So(HasType(err, "CustomError"), ShouldBeFalse)
+		})
+
+		Convey("It should encode the errors as a JSON array", func() {
+			actual, _ := json.Marshal(err)
+
+			So(string(actual), ShouldEqual, `[{"type":"TestError","code":"test_code","message":"This is a test error"},{"type":"","code":"","message":"EOF"}]`)
+		})
+	})
+}
+
 func ExampleNew() {
 	err := New("TestError", "This is a test error")
 	if err != nil {
@@ -69,3 +394,14 @@ func ExampleNew() {
 	}
 	// Output: This is a test error
 }
+
+func ExampleSNSError_Is() {
+	// A sentinel error of the type declared by the package returning the error
+	ErrTest := New("TestError", "Test error")
+
+	err := fmt.Errorf("Handling failed: %w", New("TestError", "This is a test error"))
+	if errors.Is(err, ErrTest) {
+		fmt.Print("It is a test error")
+	}
+	// Output: It is a test error
+}
@@ -0,0 +1,443 @@
+// Package snsevent provides typed payloads of common AWS event sources
+// delivered through SNS, and decodes the "Message" of a SNS message into the
+// payload of the detected event source.
+package snsevent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+)
+
+// Kinds of event
+const (
+	KindS3Event                   = "S3Event"
+	KindS3TestEvent               = "S3TestEvent"
+	KindCloudWatchAlarm           = "CloudWatchAlarm"
+	KindSESNotification           = "SESNotification"
+	KindAutoScalingLifecycleEvent = "AutoScalingLifecycleEvent"
+)
+
+// Types of SNSError returned by the package
+const (
+	ErrTypeUnknownEvent = "UnknownEvent"
+)
+
+// Sentinel errors of the types of SNSError returned by the package, to be
+// used with errors.Is
+var (
+	ErrUnknownEvent = snserrors.New(ErrTypeUnknownEvent, "Unknown event source")
+)
+
+// Subject prefixes of CloudWatch alarm notifications
+var cloudWatchAlarmSubjectPrefixes = []string{
+	"ALARM: ",
+	"OK: ",
+	"INSUFFICIENT_DATA: ",
+}
+
+// Event is the payload of a SNS message decoded by Decode. Use a type switch
+// on the concrete types to handle the events.
+type Event interface {
+	// Kind returns the kind of the event
+	Kind() string
+}
+
+// S3Event is the S3 event notification of one or more object operations
+// http://docs.aws.amazon.com/AmazonS3/latest/dev/notification-content-structure.html
+type S3Event struct {
+	Records []S3EventRecord `json:"Records"`
+}
+
+type S3EventRecord struct {
+	EventVersion         string                  `json:"eventVersion"`
+	EventSource          string                  `json:"eventSource"`
+	AWSRegion            string                  `json:"awsRegion"`
+	EventTime            time.Time               `json:"eventTime"`
+	EventName            string                  `json:"eventName"`
+	UserIdentity         S3UserIdentity          `json:"userIdentity"`
+	RequestParameters    S3RequestParameters     `json:"requestParameters"`
+	ResponseElements     map[string]string       `json:"responseElements"`
+	S3                   S3Entity                `json:"s3"`
+	GlacierEventData     *S3GlacierEventData     `json:"glacierEventData,omitempty"`
+	ReplicationEventData *S3ReplicationEventData `json:"replicationEventData,omitempty"`
+}
+
+type S3UserIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type S3RequestParameters struct {
+	SourceIPAddress string `json:"sourceIPAddress"`
+}
+
+type S3Entity struct {
+	SchemaVersion   string   `json:"s3SchemaVersion"`
+	ConfigurationID string   `json:"configurationId"`
+	Bucket          S3Bucket `json:"bucket"`
+	Object          S3Object `json:"object"`
+}
+
+type S3Bucket struct {
+	Name          string         `json:"name"`
+	OwnerIdentity S3UserIdentity `json:"ownerIdentity"`
+	Arn           string         `json:"arn"`
+}
+
+type S3Object struct {
+	// Key is URL-encoded
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"eTag"`
+	VersionID string `json:"versionId"`
+	Sequencer string `json:"sequencer"`
+}
+
+type S3GlacierEventData struct {
+	RestoreEventData struct {
+		LifecycleRestorationExpiryTime time.Time `json:"lifecycleRestorationExpiryTime"`
+		LifecycleRestoreStorageClass   string    `json:"lifecycleRestoreStorageClass"`
+	} `json:"restoreEventData"`
+}
+
+type S3ReplicationEventData struct {
+	ReplicationRuleID string `json:"replicationRuleId"`
+	DestinationBucket string `json:"destinationBucket"`
+	S3Operation       string `json:"s3Operation"`
+	RequestTime       string `json:"requestTime"`
+	FailureReason     string `json:"failureReason"`
+	Threshold         string `json:"threshold"`
+	ReplicationTime   string `json:"replicationTime"`
+}
+
+func (event *S3Event) Kind() string {
+	return KindS3Event
+}
+
+// S3TestEvent is sent by S3 when the notification configuration of a bucket
+// is created or updated
+type S3TestEvent struct {
+	Service   string    `json:"Service"`
+	Event     string    `json:"Event"`
+	Time      time.Time `json:"Time"`
+	Bucket    string    `json:"Bucket"`
+	RequestID string    `json:"RequestId"`
+	HostID    string    `json:"HostId"`
+}
+
+func (event *S3TestEvent) Kind() string {
+	return KindS3TestEvent
+}
+
+// CloudWatchAlarm is the notification of a CloudWatch alarm state change
+type CloudWatchAlarm struct {
+	AlarmName        string                 `json:"AlarmName"`
+	AlarmDescription string                 `json:"AlarmDescription"`
+	AWSAccountID     string                 `json:"AWSAccountId"`
+	NewStateValue    string                 `json:"NewStateValue"`
+	NewStateReason   string                 `json:"NewStateReason"`
+	OldStateValue    string                 `json:"OldStateValue"`
+	Region           string                 `json:"Region"`
+	AlarmArn         string                 `json:"AlarmArn"`
+	Trigger          CloudWatchAlarmTrigger `json:"Trigger"`
+
+	// StateChangeTime is not in RFC 3339 format, e.g.
+	// "2017-09-18T09:00:00.000+0000"
+	StateChangeTime string `json:"StateChangeTime"`
+}
+
+type CloudWatchAlarmTrigger struct {
+	MetricName         string                     `json:"MetricName"`
+	Namespace          string                     `json:"Namespace"`
+	StatisticType      string                     `json:"StatisticType"`
+	Statistic          string                     `json:"Statistic"`
+	Unit               string                     `json:"Unit"`
+	Dimensions         []CloudWatchAlarmDimension `json:"Dimensions"`
+	Period             int                        `json:"Period"`
+	EvaluationPeriods  int                        `json:"EvaluationPeriods"`
+	ComparisonOperator string                     `json:"ComparisonOperator"`
+	Threshold          float64                    `json:"Threshold"`
+	TreatMissingData   string                     `json:"TreatMissingData"`
+}
+
+type CloudWatchAlarmDimension struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (event *CloudWatchAlarm) Kind() string {
+	return KindCloudWatchAlarm
+}
+
+// SESNotification is the bounce, complaint or delivery notification of SES.
+// Only the field of its NotificationType is set.
+// http://docs.aws.amazon.com/ses/latest/DeveloperGuide/notification-contents.html
+type SESNotification struct {
+	NotificationType string        `json:"notificationType"`
+	Mail             SESMail       `json:"mail"`
+	Bounce           *SESBounce    `json:"bounce,omitempty"`
+	Complaint        *SESComplaint `json:"complaint,omitempty"`
+	Delivery         *SESDelivery  `json:"delivery,omitempty"`
+}
+
+type SESMail struct {
+	Timestamp        time.Time   `json:"timestamp"`
+	MessageID        string      `json:"messageId"`
+	Source           string      `json:"source"`
+	SourceArn        string      `json:"sourceArn"`
+	SourceIP         string      `json:"sourceIp"`
+	SendingAccountID string      `json:"sendingAccountId"`
+	Destination      []string    `json:"destination"`
+	HeadersTruncated bool        `json:"headersTruncated"`
+	Headers          []SESHeader `json:"headers"`
+}
+
+type SESHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type SESBounce struct {
+	BounceType        string         `json:"bounceType"`
+	BounceSubType     string         `json:"bounceSubType"`
+	BouncedRecipients []SESRecipient `json:"bouncedRecipients"`
+	Timestamp         time.Time      `json:"timestamp"`
+	FeedbackID        string         `json:"feedbackId"`
+	RemoteMTAIP       string         `json:"remoteMtaIp"`
+	ReportingMTA      string         `json:"reportingMTA"`
+}
+
+type SESComplaint struct {
+	ComplainedRecipients  []SESRecipient `json:"complainedRecipients"`
+	Timestamp             time.Time      `json:"timestamp"`
+	FeedbackID            string         `json:"feedbackId"`
+	UserAgent             string         `json:"userAgent"`
+	ComplaintFeedbackType string         `json:"complaintFeedbackType"`
+	ArrivalDate           string         `json:"arrivalDate"`
+}
+
+type SESDelivery struct {
+	Timestamp            time.Time `json:"timestamp"`
+	ProcessingTimeMillis int64     `json:"processingTimeMillis"`
+	Recipients           []string  `json:"recipients"`
+	SMTPResponse         string    `json:"smtpResponse"`
+	RemoteMTAIP          string    `json:"remoteMtaIp"`
+	ReportingMTA         string    `json:"reportingMTA"`
+}
+
+// SESRecipient is a bounced or complained recipient. Action, Status and
+// DiagnosticCode are only set for bounced recipients.
+type SESRecipient struct {
+	EmailAddress   string `json:"emailAddress"`
+	Action         string `json:"action"`
+	Status         string `json:"status"`
+	DiagnosticCode string `json:"diagnosticCode"`
+}
+
+func (event *SESNotification) Kind() string {
+	return KindSESNotification
+}
+
+// AutoScalingLifecycleEvent is the notification of an Auto Scaling lifecycle
+// hook. The test notification sent on hook creation has Event of
+// "autoscaling:TEST_NOTIFICATION" and no LifecycleTransition.
+type AutoScalingLifecycleEvent struct {
+	Origin               string    `json:"Origin"`
+	Destination          string    `json:"Destination"`
+	Service              string    `json:"Service"`
+	Time                 time.Time `json:"Time"`
+	AccountID            string    `json:"AccountId"`
+	RequestID            string    `json:"RequestId"`
+	Event                string    `json:"Event"`
+	LifecycleTransition  string    `json:"LifecycleTransition"`
+	LifecycleActionToken string    `json:"LifecycleActionToken"`
+	LifecycleHookName    string    `json:"LifecycleHookName"`
+	AutoScalingGroupName string    `json:"AutoScalingGroupName"`
+	AutoScalingGroupARN  string    `json:"AutoScalingGroupARN"`
+	EC2InstanceID        string    `json:"EC2InstanceId"`
+	NotificationMetadata string    `json:"NotificationMetadata"`
+}
+
+func (event *AutoScalingLifecycleEvent) Kind() string {
+	return KindAutoScalingLifecycleEvent
+}
+
+// probe holds the keys which tell the event sources apart
+type probe struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+	Service             string `json:"Service"`
+	Event               string `json:"Event"`
+	AlarmName           string `json:"AlarmName"`
+	NewStateValue       string `json:"NewStateValue"`
+	NotificationType    string `json:"notificationType"`
+	LifecycleTransition string `json:"LifecycleTransition"`
+}
+
+// Detector detects the kind of event of SNS messages by their "TopicArn",
+// then by their payload. The zero Detector detects the events by their
+// payload only, as the package-level functions do.
+type Detector struct {
+	// Topics maps the TopicArn of the topics to the kind of event they
+	// deliver, e.g. the topic of the S3 event notifications of a bucket to
+	// KindS3Event, so that the payload of their messages is decoded as such
+	// without being detected
+	Topics map[string]string
+}
+
+// Detect returns the kind of event in the "Message" of the SNS message. The
+// payload is detected by its content, with the "Subject" to confirm
+// CloudWatch alarms.
+// If the payload is not JSON-encoded, it returns SNSError of type
+// snsmessage.ErrMalformedPayload
+// If the payload is none of the known kinds, it returns SNSError of type
+// ErrUnknownEvent
+func Detect(message *snsmessage.SNSMessage) (string, error) {
+	return (&Detector{}).Detect(message)
+}
+
+// Detect returns the kind of event of the topic of the SNS message in Topics,
+// or detects it from the "Message" like the package-level Detect otherwise.
+// If the topic is mapped to an unknown kind, it returns SNSError of type
+// ErrUnknownEvent
+func (detector *Detector) Detect(message *snsmessage.SNSMessage) (string, error) {
+	return detector.detect(message, false)
+}
+
+// detect returns the kind of event of the SNS message, decoding its payload
+// only if it is validated when validated is true
+func (detector *Detector) detect(message *snsmessage.SNSMessage, validated bool) (string, error) {
+	if kind, ok := detector.Topics[message.TopicArn]; ok {
+		if !isKind(kind) {
+			return "", snserrors.New(
+				ErrTypeUnknownEvent,
+				fmt.Sprintf("Unknown event kind %q of the topic %s", kind, message.TopicArn),
+			)
+		}
+		return kind, nil
+	}
+
+	decode := snsmessage.DecodeMessage[probe]
+	if validated {
+		decode = snsmessage.DecodeValidatedMessage[probe]
+	}
+	p, err := decode(message)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(p.Records) > 0 && p.Records[0].EventSource == "aws:s3":
+		return KindS3Event, nil
+	case p.Service == "Amazon S3" && p.Event == "s3:TestEvent":
+		return KindS3TestEvent, nil
+	case p.AlarmName != "" && (p.NewStateValue != "" || hasAlarmSubject(message)):
+		return KindCloudWatchAlarm, nil
+	case p.NotificationType == "Bounce" ||
+		p.NotificationType == "Complaint" ||
+		p.NotificationType == "Delivery":
+		return KindSESNotification, nil
+	case p.LifecycleTransition != "" ||
+		p.Service == "AWS Auto Scaling" && p.Event == "autoscaling:TEST_NOTIFICATION":
+		return KindAutoScalingLifecycleEvent, nil
+	}
+
+	return "", snserrors.New(
+		ErrTypeUnknownEvent,
+		"Could not detect the event source of the SNS message from "+message.TopicArn,
+	)
+}
+
+// isKind returns boolean on whether the kind is one of the kinds of event
+func isKind(kind string) bool {
+	switch kind {
+	case KindS3Event, KindS3TestEvent, KindCloudWatchAlarm, KindSESNotification, KindAutoScalingLifecycleEvent:
+		return true
+	}
+	return false
+}
+
+// hasAlarmSubject returns boolean on whether the SNS message has the subject
+// of a CloudWatch alarm notification
+func hasAlarmSubject(message *snsmessage.SNSMessage) bool {
+	for _, prefix := range cloudWatchAlarmSubjectPrefixes {
+		if strings.HasPrefix(message.Subject, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode detects the kind of event in the "Message" of the SNS message and
+// returns the decoded event, which is one of *S3Event, *S3TestEvent,
+// *CloudWatchAlarm, *SESNotification and *AutoScalingLifecycleEvent.
+// It does not require the SNS message to be validated, use DecodeValidated to
+// decode only validated messages.
+// If the payload is not JSON-encoded or cannot be decoded into the event, it
+// returns SNSError of type snsmessage.ErrMalformedPayload
+// If the payload is none of the known kinds, it returns SNSError of type
+// ErrUnknownEvent
+func Decode(message *snsmessage.SNSMessage) (Event, error) {
+	return (&Detector{}).Decode(message)
+}
+
+// DecodeValidated is like Decode, but refuses to decode the SNS message that
+// has not passed Validate(), like snsmessage.DecodeValidatedMessage.
+// If the SNS message is not validated, it returns SNSError of type
+// snsmessage.ErrNotValidated
+func DecodeValidated(message *snsmessage.SNSMessage) (Event, error) {
+	return (&Detector{}).DecodeValidated(message)
+}
+
+// Decode is like the package-level Decode, with the kind of event detected
+// by Detect
+func (detector *Detector) Decode(message *snsmessage.SNSMessage) (Event, error) {
+	return detector.decode(message, false)
+}
+
+// DecodeValidated is like the package-level DecodeValidated, with the kind
+// of event detected by Detect
+func (detector *Detector) DecodeValidated(message *snsmessage.SNSMessage) (Event, error) {
+	return detector.decode(message, true)
+}
+
+// decode detects the kind of event of the SNS message and decodes it, only if
+// it is validated when validated is true
+func (detector *Detector) decode(message *snsmessage.SNSMessage, validated bool) (Event, error) {
+	kind, err := detector.detect(message, validated)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case KindS3Event:
+		return decodeAs[*S3Event](message, validated)
+	case KindS3TestEvent:
+		return decodeAs[*S3TestEvent](message, validated)
+	case KindCloudWatchAlarm:
+		return decodeAs[*CloudWatchAlarm](message, validated)
+	case KindSESNotification:
+		return decodeAs[*SESNotification](message, validated)
+	default:
+		return decodeAs[*AutoScalingLifecycleEvent](message, validated)
+	}
+}
+
+// decodeAs decodes the payload of the SNS message into the event type T, only
+// if it is validated when validated is true
+func decodeAs[T Event](message *snsmessage.SNSMessage, validated bool) (Event, error) {
+	decode := snsmessage.DecodeMessage[T]
+	if validated {
+		decode = snsmessage.DecodeValidatedMessage[T]
+	}
+	event, err := decode(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
@@ -0,0 +1,380 @@
+package snsevent
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+)
+
+// newMessage returns a SNSMessage with the given subject and message
+func newMessage(subject string, message string) *snsmessage.SNSMessage {
+	return &snsmessage.SNSMessage{
+		Type:             "Notification",
+		MessageId:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		TopicArn:         "arn:aws:sns:us-west-2:123456789012:MyTopic",
+		Message:          message,
+		Subject:          subject,
+		Timestamp:        "2012-04-26T20:45:04.751Z",
+		SignatureVersion: "1",
+		Signature:        "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
+		SigningCertURL:   "https://localhost/cert.pem",
+		UnsubscribeURL:   "https://localhost/unsubscribe",
+	}
+}
+
+var s3EventMessage = `{"Records":[{
+  "eventVersion": "2.1",
+  "eventSource": "aws:s3",
+  "awsRegion": "us-west-2",
+  "eventTime": "2017-09-18T09:00:00.000Z",
+  "eventName": "ObjectCreated:Put",
+  "userIdentity": {"principalId": "AWS:AIDAEXAMPLE"},
+  "requestParameters": {"sourceIPAddress": "127.0.0.1"},
+  "responseElements": {"x-amz-request-id": "C3D13FE58DE4C810"},
+  "s3": {
+    "s3SchemaVersion": "1.0",
+    "configurationId": "testConfigRule",
+    "bucket": {
+      "name": "mybucket",
+      "ownerIdentity": {"principalId": "A3NL1KOZZKExample"},
+      "arn": "arn:aws:s3:::mybucket"
+    },
+    "object": {
+      "key": "HappyFace.jpg",
+      "size": 1024,
+      "eTag": "d41d8cd98f00b204e9800998ecf8427e",
+      "sequencer": "0055AED6DCD90281E5"
+    }
+  }
+}]}`
+
+var s3TestEventMessage = `{
+  "Service": "Amazon S3",
+  "Event": "s3:TestEvent",
+  "Time": "2017-09-18T09:00:00.000Z",
+  "Bucket": "mybucket",
+  "RequestId": "C3D13FE58DE4C810",
+  "HostId": "FMyUVURIY8/IgAtTv8xRjskZQpcIZ9KG4V5Wp6S7S/JRWeUWerMUE5JgHvANOjpD"
+}`
+
+var cloudWatchAlarmMessage = `{
+  "AlarmName": "HighCPU",
+  "AlarmDescription": "CPU utilization is too high",
+  "AWSAccountId": "123456789012",
+  "NewStateValue": "ALARM",
+  "NewStateReason": "Threshold Crossed",
+  "StateChangeTime": "2017-09-18T09:00:00.000+0000",
+  "Region": "US West (Oregon)",
+  "AlarmArn": "arn:aws:cloudwatch:us-west-2:123456789012:alarm:HighCPU",
+  "OldStateValue": "OK",
+  "Trigger": {
+    "MetricName": "CPUUtilization",
+    "Namespace": "AWS/EC2",
+    "StatisticType": "Statistic",
+    "Statistic": "AVERAGE",
+    "Unit": null,
+    "Dimensions": [{"value": "i-0123456789abcdef0", "name": "InstanceId"}],
+    "Period": 300,
+    "EvaluationPeriods": 1,
+    "ComparisonOperator": "GreaterThanThreshold",
+    "Threshold": 80.0,
+    "TreatMissingData": "missing"
+  }
+}`
+
+var sesBounceMessage = `{
+  "notificationType": "Bounce",
+  "bounce": {
+    "bounceType": "Permanent",
+    "bounceSubType": "General",
+    "bouncedRecipients": [{
+      "emailAddress": "jane@example.com",
+      "action": "failed",
+      "status": "5.1.1",
+      "diagnosticCode": "smtp; 550 5.1.1 user unknown"
+    }],
+    "timestamp": "2017-09-18T09:00:00.000Z",
+    "feedbackId": "000001378603176d-5a4b5ad9-6f30-4198-a8c3-b1eb0c270a1d-000000",
+    "reportingMTA": "dsn; a8-70.smtp-out.amazonses.com"
+  },
+  "mail": {
+    "timestamp": "2017-09-18T08:59:59.000Z",
+    "messageId": "00000137860315fd-34208509-5b74-41f3-95c5-22c1edc3c924-000000",
+    "source": "john@example.com",
+    "sourceArn": "arn:aws:ses:us-west-2:123456789012:identity/example.com",
+    "sendingAccountId": "123456789012",
+    "destination": ["jane@example.com"]
+  }
+}`
+
+var sesComplaintMessage = `{
+  "notificationType": "Complaint",
+  "complaint": {
+    "complainedRecipients": [{"emailAddress": "jane@example.com"}],
+    "timestamp": "2017-09-18T09:00:00.000Z",
+    "feedbackId": "0000013786031775-163e3910-53eb-4c8e-a04a-f29debf88a84-000000",
+    "complaintFeedbackType": "abuse"
+  },
+  "mail": {
+    "timestamp": "2017-09-18T08:59:59.000Z",
+    "messageId": "0000013786031775-fea503bc-7497-49e1-881b-a0379bb037d3-000000",
+    "source": "john@example.com",
+    "destination": ["jane@example.com"]
+  }
+}`
+
+var autoScalingLifecycleMessage = `{
+  "Origin": "EC2",
+  "Destination": "AutoScalingGroup",
+  "Service": "AWS Auto Scaling",
+  "Time": "2017-09-18T09:00:00.000Z",
+  "AccountId": "123456789012",
+  "LifecycleTransition": "autoscaling:EC2_INSTANCE_LAUNCHING",
+  "RequestId": "c1a9b6a2-4f8e-4d1d-9c5b-0123456789ab",
+  "LifecycleActionToken": "71514b9d-6a40-4b26-8523-05e7eexample",
+  "EC2InstanceId": "i-0123456789abcdef0",
+  "LifecycleHookName": "my-hook",
+  "AutoScalingGroupName": "my-asg",
+  "NotificationMetadata": "{\"stage\":\"prod\"}"
+}`
+
+var autoScalingTestMessage = `{
+  "AccountId": "123456789012",
+  "RequestId": "c1a9b6a2-4f8e-4d1d-9c5b-0123456789ab",
+  "AutoScalingGroupARN": "arn:aws:autoscaling:us-west-2:123456789012:autoScalingGroup:uuid:autoScalingGroupName/my-asg",
+  "AutoScalingGroupName": "my-asg",
+  "Service": "AWS Auto Scaling",
+  "Event": "autoscaling:TEST_NOTIFICATION",
+  "Time": "2017-09-18T09:00:00.000Z"
+}`
+
+func TestDetect(t *testing.T) {
+	Convey("Given SNS messages of the known event sources", t, func() {
+		Convey("It should detect the kind of each event", func() {
+			for message, expected := range map[*snsmessage.SNSMessage]string{
+				newMessage("Amazon S3 Notification", s3EventMessage):                      KindS3Event,
+				newMessage("Amazon S3 Notification", s3TestEventMessage):                  KindS3TestEvent,
+				newMessage(`ALARM: "HighCPU" in US West`, cloudWatchAlarmMessage):         KindCloudWatchAlarm,
+				newMessage("", sesBounceMessage):                                          KindSESNotification,
+				newMessage("", sesComplaintMessage):                                       KindSESNotification,
+				newMessage("Auto Scaling: Lifecycle action", autoScalingLifecycleMessage): KindAutoScalingLifecycleEvent,
+				newMessage("Auto Scaling: test notification", autoScalingTestMessage):     KindAutoScalingLifecycleEvent,
+			} {
+				actual, err := Detect(message)
+
+				So(actual, ShouldEqual, expected)
+				So(err, ShouldBeNil)
+			}
+		})
+	})
+
+	Convey("Given a SNS message with an alarm name but not an alarm subject", t, func() {
+		message := newMessage("Test subject", `{"AlarmName": "HighCPU"}`)
+
+		Convey("It should return a SNSError of unknown event", func() {
+			_, err := Detect(message)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeUnknownEvent)
+		})
+	})
+
+	Convey("Given a SNS message of unknown JSON payload", t, func() {
+		message := newMessage("Test subject", `{"id": 1}`)
+
+		Convey("It should return a SNSError of unknown event", func() {
+			_, err := Detect(message)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeUnknownEvent)
+		})
+	})
+
+	Convey("Given a Detector mapping the topic of a SNS message", t, func() {
+		message := newMessage("Test subject", `{"AlarmName": "HighCPU"}`)
+		detector := &Detector{Topics: map[string]string{message.TopicArn: KindCloudWatchAlarm}}
+
+		Convey("It should detect the kind of event of the topic", func() {
+			actual, err := detector.Detect(message)
+
+			So(actual, ShouldEqual, KindCloudWatchAlarm)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("It should detect the payload of the other topics", func() {
+			other := newMessage("", sesBounceMessage)
+			other.TopicArn = "arn:aws:sns:us-west-2:123456789012:OtherTopic"
+			actual, err := detector.Detect(other)
+
+			So(actual, ShouldEqual, KindSESNotification)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("It should return a SNSError of unknown event for an unknown kind", func() {
+			detector.Topics[message.TopicArn] = "Unknown"
+			_, err := detector.Detect(message)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeUnknownEvent)
+		})
+	})
+
+	Convey("Given a SNS message of non-JSON payload", t, func() {
+		message := newMessage("Test subject", "Test notification")
+
+		Convey("It should return a SNSError of malformed payload", func() {
+			_, err := Detect(message)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, snsmessage.ErrTypeMalformedPayload)
+		})
+	})
+}
+
+func TestDecode(t *testing.T) {
+	Convey("Given a SNS message of S3 event", t, func() {
+		message := newMessage("Amazon S3 Notification", s3EventMessage)
+
+		Convey("It should return the S3Event", func() {
+			event, err := Decode(message)
+
+			So(err, ShouldBeNil)
+			So(event.Kind(), ShouldEqual, KindS3Event)
+			s3Event := event.(*S3Event)
+			So(s3Event.Records, ShouldHaveLength, 1)
+			So(s3Event.Records[0].EventName, ShouldEqual, "ObjectCreated:Put")
+			So(s3Event.Records[0].S3.Bucket.Name, ShouldEqual, "mybucket")
+			So(s3Event.Records[0].S3.Object.Key, ShouldEqual, "HappyFace.jpg")
+			So(s3Event.Records[0].S3.Object.Size, ShouldEqual, 1024)
+		})
+	})
+
+	Convey("Given a SNS message of S3 test event", t, func() {
+		message := newMessage("Amazon S3 Notification", s3TestEventMessage)
+
+		Convey("It should return the S3TestEvent", func() {
+			event, err := Decode(message)
+
+			So(err, ShouldBeNil)
+			So(event.(*S3TestEvent).Bucket, ShouldEqual, "mybucket")
+		})
+	})
+
+	Convey("Given a SNS message of CloudWatch alarm", t, func() {
+		message := newMessage(`ALARM: "HighCPU" in US West`, cloudWatchAlarmMessage)
+
+		Convey("It should return the CloudWatchAlarm", func() {
+			event, err := Decode(message)
+
+			So(err, ShouldBeNil)
+			alarm := event.(*CloudWatchAlarm)
+			So(alarm.AlarmName, ShouldEqual, "HighCPU")
+			So(alarm.NewStateValue, ShouldEqual, "ALARM")
+			So(alarm.Trigger.Threshold, ShouldEqual, 80.0)
+			So(alarm.Trigger.Dimensions, ShouldResemble, []CloudWatchAlarmDimension{
+				{Name: "InstanceId", Value: "i-0123456789abcdef0"},
+			})
+		})
+	})
+
+	Convey("Given a SNS message of SES bounce notification", t, func() {
+		message := newMessage("", sesBounceMessage)
+
+		Convey("It should return the SESNotification with bounce", func() {
+			event, err := Decode(message)
+
+			So(err, ShouldBeNil)
+			notification := event.(*SESNotification)
+			So(notification.NotificationType, ShouldEqual, "Bounce")
+			So(notification.Bounce.BounceType, ShouldEqual, "Permanent")
+			So(notification.Bounce.BouncedRecipients[0].EmailAddress, ShouldEqual, "jane@example.com")
+			So(notification.Complaint, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a SNS message of SES complaint notification", t, func() {
+		message := newMessage("", sesComplaintMessage)
+
+		Convey("It should return the SESNotification with complaint", func() {
+			event, err := Decode(message)
+
+			So(err, ShouldBeNil)
+			notification := event.(*SESNotification)
+			So(notification.Complaint.ComplaintFeedbackType, ShouldEqual, "abuse")
+			So(notification.Bounce, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a SNS message of Auto Scaling lifecycle event", t, func() {
+		message := newMessage("Auto Scaling: Lifecycle action", autoScalingLifecycleMessage)
+
+		Convey("It should return the AutoScalingLifecycleEvent", func() {
+			event, err := Decode(message)
+
+			So(err, ShouldBeNil)
+			lifecycleEvent := event.(*AutoScalingLifecycleEvent)
+			So(lifecycleEvent.LifecycleTransition, ShouldEqual, "autoscaling:EC2_INSTANCE_LAUNCHING")
+			So(lifecycleEvent.EC2InstanceID, ShouldEqual, "i-0123456789abcdef0")
+			So(lifecycleEvent.NotificationMetadata, ShouldEqual, `{"stage":"prod"}`)
+		})
+	})
+
+	Convey("Given a SNS message of an event with malformed fields", t, func() {
+		message := newMessage("", `{"notificationType": "Bounce", "mail": {"timestamp": "yesterday"}}`)
+
+		Convey("It should return a SNSError of malformed payload", func() {
+			event, err := Decode(message)
+
+			So(event, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, snsmessage.ErrTypeMalformedPayload)
+		})
+	})
+
+	Convey("Given a SNS message of unknown JSON payload", t, func() {
+		message := newMessage("Test subject", `{"id": 1}`)
+
+		Convey("It should return a SNSError of unknown event", func() {
+			event, err := Decode(message)
+
+			So(event, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeUnknownEvent)
+		})
+	})
+}
+
+func TestDecodeValidated(t *testing.T) {
+	Convey("Given a SNS message not validated", t, func() {
+		message := newMessage("Amazon S3 Notification", s3EventMessage)
+
+		Convey("It should return the error of snsmessage.DecodeValidatedMessage", func() {
+			event, err := DecodeValidated(message)
+			_, expected := snsmessage.DecodeValidatedMessage[S3Event](message)
+
+			So(event, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, snsmessage.ErrTypeNotValidated)
+			So(err, ShouldResemble, expected)
+		})
+
+		Convey("It should return the same error when the kind is mapped by the topic", func() {
+			detector := &Detector{Topics: map[string]string{message.TopicArn: KindS3Event}}
+			event, err := detector.DecodeValidated(message)
+			_, expected := snsmessage.DecodeValidatedMessage[S3Event](message)
+
+			So(event, ShouldBeNil)
+			So(err, ShouldResemble, expected)
+		})
+	})
+}
+
+func TestDetectorDecode(t *testing.T) {
+	Convey("Given a Detector mapping the topic of a SNS message of CloudWatch alarm", t, func() {
+		message := newMessage("Test subject", cloudWatchAlarmMessage)
+		detector := &Detector{Topics: map[string]string{message.TopicArn: KindCloudWatchAlarm}}
+
+		Convey("It should return the CloudWatchAlarm", func() {
+			event, err := detector.Decode(message)
+
+			So(err, ShouldBeNil)
+			So(event, ShouldHaveSameTypeAs, &CloudWatchAlarm{})
+		})
+	})
+}
@@ -0,0 +1,407 @@
+// Package snsfake provides an in-process fake SNS for end-to-end tests.
+//
+// A Server manages subscriptions of HTTP endpoints to topics. It POSTs signed
+// SubscriptionConfirmation, Notification and UnsubscribeConfirmation messages
+// to the endpoints, and serves the "SubscribeURL", "UnsubscribeURL" and
+// "SigningCertURL" of the messages it delivers. The messages pass the
+// validation of the validator returned by Validator.
+//
+// Subscribe, Publish and Unsubscribe can be called either directly or through
+// the SNS Query API of the Server, e.g.
+// POST / with Action=Publish&TopicArn=...&Message=...
+// The messages are delivered synchronously, before the calls return.
+package snsfake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snstest"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Region and account of the topics of the Server
+const (
+	Region    = "us-east-1"
+	AccountId = "123456789012"
+)
+
+// Types of SNSError returned by the Server
+const (
+	ErrTypeNotFound         = "NotFound"
+	ErrTypeInvalidParameter = "InvalidParameter"
+	ErrTypeDeliveryFailed   = "DeliveryFailed"
+)
+
+// Sentinel errors of the types of SNSError returned by the Server, to be used
+// with errors.Is
+var (
+	ErrNotFound         = snserrors.New(ErrTypeNotFound, "Subscription not found")
+	ErrInvalidParameter = snserrors.New(ErrTypeInvalidParameter, "Invalid parameter")
+	ErrDeliveryFailed   = snserrors.New(ErrTypeDeliveryFailed, "Could not deliver the SNS message")
+)
+
+// timestampFormat is the format of "Timestamp" of SNS messages
+const timestampFormat = "2006-01-02T15:04:05.000Z"
+
+// xmlns is the XML namespace of the SNS Query API responses
+const xmlns = "https://sns.amazonaws.com/doc/2010-03-31/"
+
+// Subscription records a subscription of an endpoint to a topic
+type Subscription struct {
+	Arn       string
+	TopicArn  string
+	Endpoint  string
+	Confirmed bool
+
+	token string // Token of the pending SubscriptionConfirmation
+}
+
+// Server is a fake SNS serving its signing certificate and Query API from a
+// local TLS server
+type Server struct {
+	*snstest.Server
+
+	// SignatureVersion of the delivered messages. If it is empty,
+	// snsvalidator.SignatureVersion1 is used
+	SignatureVersion string
+	// DeliveryClient POSTs the messages to the endpoints. If it is nil,
+	// http.DefaultClient is used
+	DeliveryClient *http.Client
+
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription // Subscriptions by ARN
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when
+// finished, to shut it down.
+func NewServer() *Server {
+	server := &Server{
+		Server:        snstest.NewServer(),
+		subscriptions: make(map[string]*Subscription),
+	}
+	server.Handle("/", http.HandlerFunc(server.serveAPI))
+	return server
+}
+
+// TopicArn returns the ARN of the topic with the given name. Topics need not
+// be created before use
+func TopicArn(name string) string {
+	return fmt.Sprintf("arn:aws:sns:%s:%s:%s", Region, AccountId, name)
+}
+
+// Subscribe subscribes the endpoint to the topic, and POSTs a
+// SubscriptionConfirmation to the endpoint. The subscription is pending until
+// the "SubscribeURL" of the message is visited.
+// It returns the ARN of the subscription.
+// If the endpoint is not a HTTP URL, it returns SNSError of type
+// ErrInvalidParameter
+// If the endpoint does not respond with 2xx status code, it returns SNSError
+// of type ErrDeliveryFailed. The subscription is still created.
+func (server *Server) Subscribe(topicArn string, endpoint string) (string, error) {
+	parsedUrl, err := url.Parse(endpoint)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") {
+		return "", snserrors.New(ErrTypeInvalidParameter, fmt.Sprintf("Invalid endpoint \"%s\"", endpoint))
+	}
+
+	subscription := &Subscription{
+		Arn:      topicArn + ":" + newMessageId(),
+		TopicArn: topicArn,
+		Endpoint: endpoint,
+		token:    newToken(),
+	}
+
+	server.mu.Lock()
+	server.subscriptions[subscription.Arn] = subscription
+	pending := *subscription
+	server.mu.Unlock()
+
+	message := &snsmessage.SNSMessage{
+		Type:     snsvalidator.TypeSubscriptionConfirmation,
+		Token:    pending.token,
+		TopicArn: topicArn,
+		Message: fmt.Sprintf(
+			"You have chosen to subscribe to the topic %s.\n"+
+				"To confirm the subscription, visit the SubscribeURL included in this message.",
+			topicArn,
+		),
+		SubscribeURL: server.subscribeURL(topicArn, pending.token),
+	}
+	return pending.Arn, server.deliver(pending, message)
+}
+
+// ConfirmSubscription confirms the pending subscription of the token to the
+// topic, and returns the ARN of the subscription. Visiting the "SubscribeURL"
+// of a SubscriptionConfirmation calls this method.
+// If there is no such subscription, it returns SNSError of type ErrNotFound
+func (server *Server) ConfirmSubscription(topicArn string, token string) (string, error) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	for _, subscription := range server.subscriptions {
+		if subscription.TopicArn == topicArn && subscription.token != "" && subscription.token == token {
+			subscription.Confirmed = true
+			subscription.token = ""
+			return subscription.Arn, nil
+		}
+	}
+	return "", snserrors.New(ErrTypeNotFound, "Invalid token")
+}
+
+// Publish POSTs a Notification of the message to the confirmed subscriptions
+// of the topic. The subject is optional.
+// It returns the "MessageId" of the Notification.
+// If one or more endpoints do not respond with 2xx status code, it returns
+// *snserrors.MultiError listing the SNSError of type ErrDeliveryFailed of each
+// failed delivery.
+func (server *Server) Publish(topicArn string, subject string, message string) (string, error) {
+	messageId := newMessageId()
+
+	var errs []error
+	for _, subscription := range server.Subscriptions(topicArn) {
+		if !subscription.Confirmed {
+			continue
+		}
+		errs = append(errs, server.deliver(subscription, &snsmessage.SNSMessage{
+			Type:           snsvalidator.TypeNotification,
+			MessageId:      messageId,
+			TopicArn:       topicArn,
+			Subject:        subject,
+			Message:        message,
+			UnsubscribeURL: server.unsubscribeURL(subscription.Arn),
+		}))
+	}
+	return messageId, snserrors.Join(errs...)
+}
+
+// Unsubscribe deactivates the subscription, and POSTs an
+// UnsubscribeConfirmation to its endpoint. Visiting the "UnsubscribeURL" of a
+// Notification calls this method. The subscription is pending until the
+// "SubscribeURL" of the UnsubscribeConfirmation is visited to restore it.
+// If there is no such subscription, it returns SNSError of type ErrNotFound
+// If the endpoint does not respond with 2xx status code, it returns SNSError
+// of type ErrDeliveryFailed. The subscription is still deactivated.
+func (server *Server) Unsubscribe(subscriptionArn string) error {
+	server.mu.Lock()
+	existing, exists := server.subscriptions[subscriptionArn]
+	if !exists {
+		server.mu.Unlock()
+		return snserrors.New(ErrTypeNotFound, fmt.Sprintf("Subscription \"%s\" not found", subscriptionArn))
+	}
+	existing.Confirmed = false
+	existing.token = newToken()
+	subscription := *existing
+	server.mu.Unlock()
+
+	return server.deliver(subscription, &snsmessage.SNSMessage{
+		Type:     snsvalidator.TypeUnsubscribeConfirmation,
+		Token:    subscription.token,
+		TopicArn: subscription.TopicArn,
+		Message: fmt.Sprintf(
+			"You have chosen to deactivate subscription %s.\n"+
+				"To cancel this operation and restore the subscription, visit the SubscribeURL included in this message.",
+			subscriptionArn,
+		),
+		SubscribeURL: server.subscribeURL(subscription.TopicArn, subscription.token),
+	})
+}
+
+// Subscriptions returns a copy of the subscriptions to the topic, confirmed or
+// pending, sorted by ARN
+func (server *Server) Subscriptions(topicArn string) []Subscription {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	var subscriptions []Subscription
+	for _, subscription := range server.subscriptions {
+		if subscription.TopicArn == topicArn {
+			subscriptions = append(subscriptions, *subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Arn < subscriptions[j].Arn
+	})
+	return subscriptions
+}
+
+// subscribeURL returns the URL confirming the subscription of the token
+func (server *Server) subscribeURL(topicArn string, token string) string {
+	return server.URL + "/?" + url.Values{
+		"Action":   {"ConfirmSubscription"},
+		"TopicArn": {topicArn},
+		"Token":    {token},
+	}.Encode()
+}
+
+// unsubscribeURL returns the URL deleting the subscription
+func (server *Server) unsubscribeURL(subscriptionArn string) string {
+	return server.URL + "/?" + url.Values{
+		"Action":          {"Unsubscribe"},
+		"SubscriptionArn": {subscriptionArn},
+	}.Encode()
+}
+
+// deliver signs and POSTs the message to the endpoint of the subscription with
+// the headers SNS sends.
+func (server *Server) deliver(subscription Subscription, message *snsmessage.SNSMessage) error {
+	if message.MessageId == "" {
+		message.MessageId = newMessageId()
+	}
+	message.Timestamp = time.Now().UTC().Format(timestampFormat)
+
+	signatureVersion := server.SignatureVersion
+	if signatureVersion == "" {
+		signatureVersion = snsvalidator.SignatureVersion1
+	}
+	if err := server.Sign(message, signatureVersion); err != nil {
+		return snserrors.Wrap(ErrTypeInvalidParameter, err, "")
+	}
+
+	body, err := encodeMessage(message)
+	if err != nil {
+		return snserrors.Wrap(ErrTypeDeliveryFailed, err, "")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return snserrors.Wrap(ErrTypeDeliveryFailed, err, "")
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=UTF-8")
+	req.Header.Set("x-amz-sns-message-type", message.Type)
+	req.Header.Set("x-amz-sns-message-id", message.MessageId)
+	req.Header.Set("x-amz-sns-topic-arn", message.TopicArn)
+	if message.Type == snsvalidator.TypeNotification {
+		req.Header.Set("x-amz-sns-subscription-arn", subscription.Arn)
+	}
+
+	client := server.DeliveryClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return snserrors.Wrap(ErrTypeDeliveryFailed, err, "")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return snserrors.New(
+			ErrTypeDeliveryFailed,
+			fmt.Sprintf("Endpoint \"%s\" responded with %d", subscription.Endpoint, res.StatusCode),
+		).WithDetails(snserrors.Details{MessageType: message.Type, StatusCode: res.StatusCode})
+	}
+	return nil
+}
+
+// encodeMessage returns the JSON encoding of the message without the absent
+// keys, as SNS does
+func encodeMessage(message *snsmessage.SNSMessage) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+// newMessageId returns a random UUID
+func newMessageId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newToken returns a random subscription token
+func newToken() string {
+	b := make([]byte, 64)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// xmlResponse is the response of the SNS Query API
+type xmlResponse struct {
+	XMLName xml.Name
+	Xmlns   string `xml:"xmlns,attr"`
+	Result  *xmlResult
+}
+
+// xmlResult is the result of the SNS Query API response
+type xmlResult struct {
+	XMLName         xml.Name
+	SubscriptionArn string `xml:",omitempty"`
+	MessageId       string `xml:",omitempty"`
+}
+
+// xmlErrorResponse is the error response of the SNS Query API
+type xmlErrorResponse struct {
+	XMLName xml.Name `xml:"ErrorResponse"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Error   struct {
+		Type    string
+		Code    string
+		Message string
+	}
+}
+
+// serveAPI serves the Subscribe, ConfirmSubscription, Publish and Unsubscribe
+// actions of the SNS Query API
+func (server *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	action := r.FormValue("Action")
+	result := &xmlResult{XMLName: xml.Name{Local: action + "Result"}}
+
+	var err error
+	switch action {
+	case "Subscribe":
+		if protocol := r.FormValue("Protocol"); protocol != "http" && protocol != "https" {
+			err = snserrors.New(ErrTypeInvalidParameter, fmt.Sprintf("Unsupported protocol \"%s\"", protocol))
+			break
+		}
+		result.SubscriptionArn, err = server.Subscribe(r.FormValue("TopicArn"), r.FormValue("Endpoint"))
+	case "ConfirmSubscription":
+		result.SubscriptionArn, err = server.ConfirmSubscription(r.FormValue("TopicArn"), r.FormValue("Token"))
+	case "Publish":
+		result.MessageId, err = server.Publish(r.FormValue("TopicArn"), r.FormValue("Subject"), r.FormValue("Message"))
+	case "Unsubscribe":
+		err = server.Unsubscribe(r.FormValue("SubscriptionArn"))
+		result = nil
+	default:
+		err = snserrors.New(ErrTypeInvalidParameter, fmt.Sprintf("Unsupported action \"%s\"", action))
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	if err != nil && !snserrors.HasType(err, ErrTypeDeliveryFailed) {
+		writeError(w, err)
+		return
+	}
+
+	// Like SNS, failed deliveries are not reported to the caller
+	xml.NewEncoder(w).Encode(xmlResponse{
+		XMLName: xml.Name{Local: action + "Response"},
+		Xmlns:   xmlns,
+		Result:  result,
+	})
+}
+
+// writeError writes the SNS Query API error response of the error
+func writeError(w http.ResponseWriter, err error) {
+	response := xmlErrorResponse{Xmlns: xmlns}
+	response.Error.Type = "Sender"
+	response.Error.Message = err.Error()
+	if snserrors.HasType(err, ErrTypeNotFound) {
+		response.Error.Code = ErrTypeNotFound
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		response.Error.Code = ErrTypeInvalidParameter
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	xml.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,253 @@
+package snsfake
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// endpoint is a HTTP endpoint recording the SNS messages it receives, after
+// validating them with the validator of the fake SNS. It confirms
+// subscriptions by visiting the "SubscribeURL".
+type endpoint struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	messages []*snsmessage.SNSMessage
+	headers  []http.Header
+	errs     []error
+}
+
+func newEndpoint(server *Server, status int) *endpoint {
+	e := &endpoint{}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message, err := snsmessage.NewFromReader(r.Body, 0)
+		if err == nil {
+			err = server.Validator(message).ValidateMessage()
+		}
+		if err == nil && message.Type == snsvalidator.TypeSubscriptionConfirmation {
+			var res *http.Response
+			if res, err = server.Client().Get(message.SubscribeURL); err == nil {
+				res.Body.Close()
+			}
+		}
+
+		e.mu.Lock()
+		e.messages = append(e.messages, message)
+		e.headers = append(e.headers, r.Header)
+		e.errs = append(e.errs, err)
+		e.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	return e
+}
+
+func (e *endpoint) last() (*snsmessage.SNSMessage, http.Header, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.messages) == 0 {
+		return nil, nil, nil
+	}
+	i := len(e.messages) - 1
+	return e.messages[i], e.headers[i], e.errs[i]
+}
+
+func (e *endpoint) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.messages)
+}
+
+func TestSubscriptionFlow(t *testing.T) {
+	for _, signatureVersion := range []string{snsvalidator.SignatureVersion1, snsvalidator.SignatureVersion2} {
+		Convey("Given a fake SNS signing with signature version "+signatureVersion, t, func() {
+			server := NewServer()
+			defer server.Close()
+			server.SignatureVersion = signatureVersion
+
+			e := newEndpoint(server, http.StatusOK)
+			defer e.Close()
+
+			topicArn := TopicArn("MyTopic")
+
+			Convey("When an endpoint subscribes to a topic", func() {
+				subscriptionArn, err := server.Subscribe(topicArn, e.URL)
+				So(err, ShouldBeNil)
+
+				Convey("It should receive a valid SubscriptionConfirmation and confirm it", func() {
+					message, headers, err := e.last()
+
+					So(err, ShouldBeNil)
+					So(message.Type, ShouldEqual, snsvalidator.TypeSubscriptionConfirmation)
+					So(message.SignatureVersion, ShouldEqual, signatureVersion)
+					So(headers.Get("x-amz-sns-message-type"), ShouldEqual, snsvalidator.TypeSubscriptionConfirmation)
+					So(server.Subscriptions(topicArn), ShouldResemble, []Subscription{
+						{Arn: subscriptionArn, TopicArn: topicArn, Endpoint: e.URL, Confirmed: true},
+					})
+				})
+
+				Convey("When a message is published to the topic", func() {
+					messageId, err := server.Publish(topicArn, "Test subject", "Test notification")
+					So(err, ShouldBeNil)
+
+					Convey("It should receive a valid Notification", func() {
+						message, headers, err := e.last()
+
+						So(err, ShouldBeNil)
+						So(message.Type, ShouldEqual, snsvalidator.TypeNotification)
+						So(message.MessageId, ShouldEqual, messageId)
+						So(message.Subject, ShouldEqual, "Test subject")
+						So(message.Message, ShouldEqual, "Test notification")
+						So(headers.Get("x-amz-sns-subscription-arn"), ShouldEqual, subscriptionArn)
+					})
+
+					Convey("When the endpoint visits the UnsubscribeURL", func() {
+						message, _, _ := e.last()
+						res, err := server.Client().Get(message.UnsubscribeURL)
+						So(err, ShouldBeNil)
+						res.Body.Close()
+
+						Convey("It should receive a valid UnsubscribeConfirmation", func() {
+							message, _, err := e.last()
+
+							So(err, ShouldBeNil)
+							So(message.Type, ShouldEqual, snsvalidator.TypeUnsubscribeConfirmation)
+							So(server.Subscriptions(topicArn)[0].Confirmed, ShouldBeFalse)
+						})
+
+						Convey("It should not receive the messages published afterwards", func() {
+							count := e.count()
+							server.Publish(topicArn, "", "Test notification")
+
+							So(e.count(), ShouldEqual, count)
+						})
+					})
+				})
+			})
+		})
+	}
+}
+
+func TestPublishMethod(t *testing.T) {
+	Convey("Given a fake SNS with a failing endpoint subscribed", t, func() {
+		server := NewServer()
+		defer server.Close()
+
+		e := newEndpoint(server, http.StatusInternalServerError)
+		defer e.Close()
+
+		topicArn := TopicArn("MyTopic")
+		_, err := server.Subscribe(topicArn, e.URL)
+
+		So(errors.Is(err, ErrDeliveryFailed), ShouldBeTrue)
+
+		Convey("It should report the failed delivery", func() {
+			_, err := server.Publish(topicArn, "", "Test notification")
+
+			So(errors.Is(err, ErrDeliveryFailed), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a fake SNS without subscription", t, func() {
+		server := NewServer()
+		defer server.Close()
+
+		Convey("It should publish to nobody", func() {
+			messageId, err := server.Publish(TopicArn("MyTopic"), "", "Test notification")
+
+			So(messageId, ShouldNotBeEmpty)
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestSubscribeMethod(t *testing.T) {
+	Convey("Given a fake SNS", t, func() {
+		server := NewServer()
+		defer server.Close()
+
+		Convey("It should reject non-HTTP endpoints", func() {
+			_, err := server.Subscribe(TopicArn("MyTopic"), "mailto:test@example.com")
+
+			So(errors.Is(err, ErrInvalidParameter), ShouldBeTrue)
+		})
+
+		Convey("It should reject invalid tokens", func() {
+			_, err := server.ConfirmSubscription(TopicArn("MyTopic"), "invalid-token")
+
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+		})
+
+		Convey("It should reject unknown subscriptions", func() {
+			err := server.Unsubscribe(TopicArn("MyTopic") + ":unknown")
+
+			So(errors.Is(err, ErrNotFound), ShouldBeTrue)
+		})
+	})
+}
+
+func TestQueryAPI(t *testing.T) {
+	Convey("Given a fake SNS with an endpoint subscribed through the Query API", t, func() {
+		server := NewServer()
+		defer server.Close()
+
+		e := newEndpoint(server, http.StatusOK)
+		defer e.Close()
+
+		topicArn := TopicArn("MyTopic")
+		res, err := server.Client().PostForm(server.URL, url.Values{
+			"Action":   {"Subscribe"},
+			"TopicArn": {topicArn},
+			"Protocol": {"http"},
+			"Endpoint": {e.URL},
+		})
+		So(err, ShouldBeNil)
+		res.Body.Close()
+
+		So(res.StatusCode, ShouldEqual, http.StatusOK)
+		So(server.Subscriptions(topicArn)[0].Confirmed, ShouldBeTrue)
+
+		Convey("It should publish and respond with the MessageId", func() {
+			res, err := server.Client().PostForm(server.URL, url.Values{
+				"Action":   {"Publish"},
+				"TopicArn": {topicArn},
+				"Message":  {"Test notification"},
+			})
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+
+			var response struct {
+				MessageId string `xml:"PublishResult>MessageId"`
+			}
+			xml.NewDecoder(res.Body).Decode(&response)
+
+			message, _, err := e.last()
+			So(err, ShouldBeNil)
+			So(message.MessageId, ShouldEqual, response.MessageId)
+		})
+
+		Convey("It should respond with an error to unsupported actions", func() {
+			res, err := server.Client().PostForm(server.URL, url.Values{"Action": {"CreateTopic"}})
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+
+			body, _ := ioutil.ReadAll(res.Body)
+			So(res.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(strings.Contains(string(body), "<Code>InvalidParameter</Code>"), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,137 @@
+// Package snshttp provides a HTTP middleware which validates the SNS message
+// of the incoming request before handing it over to the next handler.
+//
+// SNS retries the delivery of a message if the endpoint responds with a 5xx
+// status code. The middleware responds with a 5xx status code only if the
+// validation failure is temporary, e.g. the signing certificate could not be
+// retrieved, so that a forged or malformed message is not redelivered.
+package snshttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/yuhlau/go-sns-message-validator/snsaudit"
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// contextKey is the key of the validated SNSMessage in the request context
+type contextKey struct{}
+
+// Middleware returns a handler which decodes and validates the SNS message in
+// the request body, and calls the next handler with the validated SNSMessage
+// stored in the request context. Use MessageFromContext to retrieve it.
+// If the SNS message is invalid, it responds with the status code returned by
+// StatusCode and the error message, and the next handler is not called.
+func Middleware(next http.Handler) http.Handler {
+	return newMiddleware((*snsmessage.SNSMessage).ValidateContext, nil, next)
+}
+
+// VerifierMiddleware is like Middleware, but validates the SNS messages with
+// the Verifier, so that its configuration and certificate cache are shared
+// across the requests.
+func VerifierMiddleware(verifier *snsvalidator.Verifier, next http.Handler) http.Handler {
+	return newMiddleware(verifyWith(verifier), nil, next)
+}
+
+// AuditMiddleware is like VerifierMiddleware, and records the outcome of each
+// request with the Auditor. The record has the remote address of the request
+// as source, and the hash of the request body.
+func AuditMiddleware(verifier *snsvalidator.Verifier, auditor *snsaudit.Auditor, next http.Handler) http.Handler {
+	return newMiddleware(verifyWith(verifier), auditor, next)
+}
+
+// verifyWith returns the function validating the SNS messages with the
+// Verifier
+func verifyWith(verifier *snsvalidator.Verifier) func(*snsmessage.SNSMessage, context.Context) error {
+	return func(message *snsmessage.SNSMessage, ctx context.Context) error {
+		return message.VerifyWith(ctx, verifier)
+	}
+}
+
+// newMiddleware returns the middleware validating the SNS messages with the
+// validate function, and auditing them with the auditor if it is not nil
+func newMiddleware(
+	validate func(*snsmessage.SNSMessage, context.Context) error, auditor *snsaudit.Auditor, next http.Handler,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		var auditedBody *snsaudit.BodyReader
+		if auditor != nil {
+			auditedBody = snsaudit.NewBodyReader(body, snsmessage.DefaultReadLimit)
+			body = auditedBody
+		}
+
+		message, err := snsmessage.NewFromReader(body, snsmessage.DefaultReadLimit)
+		if err == nil {
+			err = validate(message, r.Context())
+		}
+		if auditor != nil {
+			audit(r, auditor, auditedBody, message, err)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), StatusCode(err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, message)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// audit records the outcome of the request with the auditor
+func audit(r *http.Request, auditor *snsaudit.Auditor, body *snsaudit.BodyReader, message *snsmessage.SNSMessage, err error) {
+	record := snsaudit.NewRecord(validatorMessage(message), err)
+	record.Source = r.RemoteAddr
+	// A failure to read the rest of the body leaves the hash of the body read
+	// so far, which is still worth recording
+	body.Drain()
+	body.Fill(&record)
+
+	auditor.Audit(r.Context(), record)
+}
+
+// validatorMessage returns the SNSMessage as snsvalidator.Message. A nil
+// SNSMessage, which could not be decoded, is returned as a nil interface
+// rather than an interface holding a nil pointer, so that NewRecord sees no
+// message.
+func validatorMessage(message *snsmessage.SNSMessage) snsvalidator.Message {
+	if message == nil {
+		return nil
+	}
+	return message
+}
+
+// MessageFromContext returns the validated SNSMessage stored in the context by
+// Middleware, and whether it is present
+func MessageFromContext(ctx context.Context) (*snsmessage.SNSMessage, bool) {
+	message, ok := ctx.Value(contextKey{}).(*snsmessage.SNSMessage)
+	return message, ok
+}
+
+// StatusCode returns the HTTP status code to respond to SNS with for the
+// validation error.
+//   - nil: 200 OK
+//   - temporary errors: 503 Service Unavailable, for SNS to retry
+//   - ErrMessageTooLarge: 413 Request Entity Too Large
+//   - ErrInvalidCert and ErrIncorrectSignature: 403 Forbidden
+//   - any other error: 400 Bad Request
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case snserrors.IsTemporary(err):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, snsmessage.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, snsvalidator.ErrInvalidCert),
+		errors.Is(err, snsvalidator.ErrIncorrectSignature):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,262 @@
+package snshttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/h2non/gock.v1"
+
+	"github.com/yuhlau/go-sns-message-validator/snsaudit"
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snstest"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Notification signed by _assets/fakecert.key
+var notificationMessage = snsmessage.SNSMessage{
+	Type:             "Notification",
+	MessageId:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+	TopicArn:         "arn:aws:sns:us-west-2:123456789012:MyTopic",
+	Message:          `{"id":1,"name":"test"}`,
+	Subject:          "Test subject",
+	Timestamp:        "2012-04-26T20:45:04.751Z",
+	SignatureVersion: "1",
+	Signature:        "Z2ZxqGoxh1zOankzqfvCMZlSHaWriMB8SlH36camvWEBpLvha2P5Y3nm1pCWW+OvomleeFeME6LMsCaysV5R8eESfmLvxQ5U5ETNVOSheEVfzVWxUTV6nSrgiq0OomOMyKGE2FbFGyhuARAvZSMKGLjvQraRqJ/Pb/y6wIYeLbU=",
+	SigningCertURL:   "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+	UnsubscribeURL:   "https://localhost/unsubscribe",
+}
+
+// serve sends the message to the Middleware and returns the response and the
+// SNSMessage received by the next handler
+func serve(message snsmessage.SNSMessage) (*httptest.ResponseRecorder, *snsmessage.SNSMessage) {
+	var received *snsmessage.SNSMessage
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = MessageFromContext(r.Context())
+	}))
+
+	body, _ := json.Marshal(message)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body))))
+	return recorder, received
+}
+
+func TestMiddleware(t *testing.T) {
+	Convey("Given a request of valid SNS message", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			File("../_assets/fakecert.pem")
+
+		Convey("It should call the next handler with the validated SNSMessage", func() {
+			recorder, received := serve(notificationMessage)
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(received, ShouldNotBeNil)
+			So(received.MessageId, ShouldEqual, notificationMessage.MessageId)
+			So(received.IsValidated(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a request of SNS message with incorrect signature", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			File("../_assets/fakecert.pem")
+
+		message := notificationMessage
+		message.Message = "Forged notification"
+
+		Convey("It should respond with 403 without calling the next handler", func() {
+			recorder, received := serve(message)
+
+			So(recorder.Code, ShouldEqual, http.StatusForbidden)
+			So(received, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a request of SNS message while the certificate host is unavailable", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(502)
+
+		Convey("It should respond with 503 for SNS to retry", func() {
+			recorder, received := serve(notificationMessage)
+
+			So(recorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(received, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a request of malformed JSON", t, func() {
+		handler := Middleware(http.NotFoundHandler())
+		recorder := httptest.NewRecorder()
+
+		Convey("It should respond with 400", func() {
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{")))
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+func TestVerifierMiddleware(t *testing.T) {
+	Convey("Given a Verifier trusting a local certificate server", t, func() {
+		server := snstest.NewServer()
+		defer server.Close()
+
+		verifier := snsvalidator.NewVerifier(
+			snsvalidator.WithHTTPClient(server.Client()),
+			snsvalidator.WithHostPattern(server.HostPattern()),
+		)
+
+		var received *snsmessage.SNSMessage
+		handler := VerifierMiddleware(verifier, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received, _ = MessageFromContext(r.Context())
+		}))
+
+		Convey("It should call the next handler with the message signed by the server", func() {
+			message := notificationMessage
+			server.Sign(&message, snsvalidator.SignatureVersion2)
+			body, _ := json.Marshal(message)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body))))
+
+			So(recorder.Code, ShouldEqual, http.StatusOK)
+			So(received.IsValidated(), ShouldBeTrue)
+		})
+
+		Convey("It should reject the message signed by AWS", func() {
+			body, _ := json.Marshal(notificationMessage)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body))))
+
+			So(recorder.Code, ShouldEqual, http.StatusForbidden)
+			So(received, ShouldBeNil)
+		})
+	})
+}
+
+// memorySink records the audit records in memory
+type memorySink struct {
+	records []snsaudit.Record
+}
+
+func (sink *memorySink) Audit(ctx context.Context, record snsaudit.Record) error {
+	sink.records = append(sink.records, record)
+	return nil
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	Convey("Given an audited Verifier trusting a local certificate server", t, func() {
+		server := snstest.NewServer()
+		defer server.Close()
+
+		verifier := snsvalidator.NewVerifier(
+			snsvalidator.WithHTTPClient(server.Client()),
+			snsvalidator.WithHostPattern(server.HostPattern()),
+		)
+		sink := &memorySink{}
+		auditor := &snsaudit.Auditor{Sink: sink}
+		handler := AuditMiddleware(verifier, auditor, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		send := func(body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			request.RemoteAddr = "203.0.113.1:1234"
+			handler.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		Convey("It should audit the rejected message with its source and body hash", func() {
+			body, _ := json.Marshal(notificationMessage)
+			recorder := send(string(body))
+			sum := sha256.Sum256(body)
+
+			So(recorder.Code, ShouldEqual, http.StatusForbidden)
+			So(len(sink.records), ShouldEqual, 1)
+
+			record := sink.records[0]
+			So(record.Accepted, ShouldBeFalse)
+			So(record.Reason, ShouldEqual, snsvalidator.ErrTypeInvalidCert)
+			So(record.Source, ShouldEqual, "203.0.113.1:1234")
+			So(record.MessageId, ShouldEqual, notificationMessage.MessageId)
+			So(record.TopicArn, ShouldEqual, notificationMessage.TopicArn)
+			So(record.BodySHA256, ShouldEqual, hex.EncodeToString(sum[:]))
+			So(record.BodySize, ShouldEqual, len(body))
+		})
+
+		Convey("It should audit the malformed body", func() {
+			recorder := send("{")
+
+			So(recorder.Code, ShouldEqual, http.StatusBadRequest)
+			So(len(sink.records), ShouldEqual, 1)
+			So(sink.records[0].MessageId, ShouldBeEmpty)
+			So(sink.records[0].BodySize, ShouldEqual, 1)
+		})
+
+		Convey("It should hash the whole malformed body, not only the part decoded", func() {
+			body := `{"Type":"Notification",x` + strings.Repeat(" ", 64*1024) + `}`
+			send(body)
+			sum := sha256.Sum256([]byte(body))
+
+			So(len(sink.records), ShouldEqual, 1)
+			So(sink.records[0].BodySHA256, ShouldEqual, hex.EncodeToString(sum[:]))
+			So(sink.records[0].BodySize, ShouldEqual, len(body))
+			So(sink.records[0].BodyTruncated, ShouldBeFalse)
+		})
+
+		Convey("It should not audit the accepted message by default", func() {
+			message := notificationMessage
+			server.Sign(&message, snsvalidator.SignatureVersion2)
+			body, _ := json.Marshal(message)
+
+			So(send(string(body)).Code, ShouldEqual, http.StatusOK)
+			So(sink.records, ShouldBeEmpty)
+
+			Convey("It should audit it if the accepted messages are included", func() {
+				auditor.IncludeAccepted = true
+				send(string(body))
+
+				So(len(sink.records), ShouldEqual, 1)
+				So(sink.records[0].Accepted, ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestMessageFromContext(t *testing.T) {
+	Convey("Given a context without SNSMessage", t, func() {
+		Convey("It should return false", func() {
+			message, ok := MessageFromContext(context.Background())
+
+			So(message, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestStatusCode(t *testing.T) {
+	Convey("Given validation errors", t, func() {
+		Convey("It should map them to HTTP status codes", func() {
+			So(StatusCode(nil), ShouldEqual, http.StatusOK)
+			So(StatusCode(snsvalidator.ErrInvalidCert.WithTemporary(true)), ShouldEqual, http.StatusServiceUnavailable)
+			So(StatusCode(snserrors.Wrap(snsvalidator.ErrTypeInvalidCert, context.DeadlineExceeded, "")), ShouldEqual, http.StatusServiceUnavailable)
+			So(StatusCode(snsmessage.ErrMessageTooLarge), ShouldEqual, http.StatusRequestEntityTooLarge)
+			So(StatusCode(snsvalidator.ErrInvalidCert), ShouldEqual, http.StatusForbidden)
+			So(StatusCode(snsvalidator.ErrIncorrectSignature), ShouldEqual, http.StatusForbidden)
+			So(StatusCode(snsvalidator.ErrMissingKey), ShouldEqual, http.StatusBadRequest)
+			So(StatusCode(errors.New("unknown")), ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
@@ -3,35 +3,79 @@
 package snsmessage
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 
+	"github.com/yuhlau/go-sns-message-validator/internal/validation"
 	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmodel"
 	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
 )
 
+// Types of SNSError returned by the package
 const (
-	ErrMalformedJSON = "MalformedJSON"
+	ErrTypeMalformedJSON      = "MalformedJSON"
+	ErrTypeMessageTooLarge    = "MessageTooLarge"
+	ErrTypeInvalidSequence    = "InvalidSequence"
+	ErrTypeMalformedPayload   = "MalformedPayload"
+	ErrTypeNotValidated       = "NotValidated"
+	ErrTypeMalformedStructure = "MalformedStructure"
 )
 
+// Machine-readable codes of SNSError returned by NewFromJSONStrict
+const (
+	CodeUnexpectedKey  = "unexpected_key"
+	CodeDuplicateKey   = "duplicate_key"
+	CodeNonStringValue = "non_string_value"
+)
+
+// Sentinel errors of the types of SNSError returned by the package, to be
+// used with errors.Is
+var (
+	ErrMalformedJSON      = snserrors.New(ErrTypeMalformedJSON, "Malformed JSON SNS message")
+	ErrMessageTooLarge    = snserrors.New(ErrTypeMessageTooLarge, "SNS message is too large")
+	ErrInvalidSequence    = snserrors.New(ErrTypeInvalidSequence, "Invalid SequenceNumber")
+	ErrMalformedPayload   = snserrors.New(ErrTypeMalformedPayload, "Malformed SNS message payload")
+	ErrNotValidated       = snserrors.New(ErrTypeNotValidated, "SNS message is not validated")
+	ErrMalformedStructure = snserrors.New(ErrTypeMalformedStructure, "Malformed structured message")
+)
+
+// Protocols of SNS subscriptions with a specific message when publishing with
+// MessageStructure=json
+const (
+	ProtocolDefault = "default"
+	ProtocolHTTP    = "http"
+	ProtocolHTTPS   = "https"
+	ProtocolSQS     = "sqs"
+	ProtocolLambda  = "lambda"
+)
+
+const (
+	// MaxMessageSize is the maximum size of the message published to SNS
+	MaxMessageSize = 256 * 1024
+	// DefaultReadLimit is the default number of bytes NewFromReader reads. It
+	// leaves room for the envelope keys and JSON escaping of a message of
+	// MaxMessageSize
+	DefaultReadLimit = MaxMessageSize + 64*1024
+)
+
+// errLimitExceeded is returned by limitedReader when the input is longer than
+// its limit
+var errLimitExceeded = errors.New("read limit exceeded")
+
 // SNSMessage structure
-// This structure can serve both Lambda and server end point SNS Message.
-// SNS Message delivered to Lambda function will have its "*URL" fields end
-// with camelcase "Url" instead of all uppdercase "URL". Go json.Unmarshal()
-// will first try an exact match of struct field name of its tag, then accepts
-// a case-insensitive match.
-type SNSMessage struct {
-	Type             string `json:"Type"`
-	MessageId        string `json:"MessageId"`
-	Token            string `json:"Token"`
-	TopicArn         string `json:"TopicArn"`
-	Message          string `json:"Message"`
-	Subject          string `json:"Subject"`
-	SubscribeURL     string `json:"SubscribeURL"`
-	Timestamp        string `json:"Timestamp"`
-	SignatureVersion string `json:"SignatureVersion"`
-	Signature        string `json:"Signature"`
-	SigningCertURL   string `json:"SigningCertURL"`
-	UnsubscribeURL   string `json:"UnsubscribeURL"`
+// SNSMessage is the snsmodel.Message with the utilities of the package, see
+// snsmodel.Message for its fields and how they are decoded.
+type SNSMessage snsmodel.Message
+
+// model returns the SNSMessage as snsmodel.Message
+func (message *SNSMessage) model() *snsmodel.Message {
+	return (*snsmodel.Message)(message)
 }
 
 // Create a SNSMessage from JSON-encoded SNS message
@@ -39,31 +83,347 @@ func NewFromJSON(encoded []byte) (*SNSMessage, error) {
 	message := &SNSMessage{}
 	err := json.Unmarshal(encoded, message)
 	if err != nil {
-		return nil, snserrors.New(ErrMalformedJSON, err.Error())
+		return nil, snserrors.Wrap(ErrTypeMalformedJSON, err, "")
 	}
 
 	return message, nil
 }
 
-// Transform the SNSMessage structure to map
-func (message *SNSMessage) toMap() map[string]string {
-	return map[string]string{
-		"Type":             message.Type,
-		"MessageId":        message.MessageId,
-		"Token":            message.Token,
-		"TopicArn":         message.TopicArn,
-		"Message":          message.Message,
-		"Timestamp":        message.Timestamp,
-		"Subject":          message.Subject,
-		"SubscribeURL":     message.SubscribeURL,
-		"SignatureVersion": message.SignatureVersion,
-		"Signature":        message.Signature,
-		"SigningCertURL":   message.SigningCertURL,
-		"UnsubscribeURL":   message.UnsubscribeURL,
+// Create a SNSMessage by decoding JSON-encoded SNS message directly from the
+// reader, such as a HTTP request body, with snsmodel.Message.DecodeJSON. The
+// input is not buffered as a whole: each value, such as the "Message", is
+// unescaped into a reused buffer and allocated once as its field. At most
+// limit bytes are read from the reader. A non-positive limit means
+// DefaultReadLimit.
+// If the input is longer than the limit, it returns SNSError of type
+// ErrMessageTooLarge
+// If the JSON is malformed, it returns SNSError of type ErrMalformedJSON
+func NewFromReader(reader io.Reader, limit int64) (*SNSMessage, error) {
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+	limited := &limitedReader{reader: reader, remaining: limit}
+
+	message := &SNSMessage{}
+	err := message.model().DecodeJSON(limited)
+	if err == nil {
+		return message, nil
+	}
+
+	if limited.exceeded {
+		return nil, snserrors.New(
+			ErrTypeMessageTooLarge,
+			fmt.Sprintf("SNS message is larger than %d bytes", limit),
+		)
+	}
+	return nil, snserrors.Wrap(ErrTypeMalformedJSON, err, "")
+}
+
+// limitedReader reads from the underlying reader until the remaining bytes
+// are exhausted. Unlike io.LimitedReader, it returns errLimitExceeded instead
+// of io.EOF if the underlying reader has more to read than the limit.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.exceeded {
+		return 0, errLimitExceeded
+	}
+
+	// Read one more byte than remaining to detect input over the limit
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	if int64(n) <= r.remaining {
+		r.remaining -= int64(n)
+		return n, err
+	}
+
+	n = int(r.remaining)
+	r.remaining = 0
+	r.exceeded = true
+	return n, errLimitExceeded
+}
+
+// Create a SNSMessage from JSON-encoded SNS message in strict mode. Unlike
+// NewFromJSON, the JSON object must only contain string values of the known
+// SNS message keys, each appearing once. Keys which only differ in case, like
+// "SigningCertURL" and "SigningCertUrl", are considered duplicated.
+// If the JSON is malformed or violates any of the rules, it returns SNSError
+// of type ErrMalformedJSON naming the offending key
+func NewFromJSONStrict(encoded []byte) (*SNSMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	message := &SNSMessage{}
+
+	if token, err := decoder.Token(); err != nil {
+		return nil, snserrors.Wrap(ErrTypeMalformedJSON, err, "")
+	} else if token != json.Delim('{') {
+		return nil, snserrors.New(ErrTypeMalformedJSON, "SNS message is not a JSON object")
+	}
+
+	// Record the original key of each decoded key to detect duplicates
+	decodedKeys := make(map[string]string)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, snserrors.Wrap(ErrTypeMalformedJSON, err, "")
+		}
+		key := token.(string)
+
+		canonicalKey, known := snsmodel.CanonicalKey(key)
+		if !known {
+			return nil, snserrors.New(
+				ErrTypeMalformedJSON,
+				fmt.Sprintf("Unexpected key \"%s\" in SNS message", key),
+			).WithCode(CodeUnexpectedKey).WithDetails(snserrors.Details{Key: key})
+		}
+		if decodedKey, decoded := decodedKeys[canonicalKey]; decoded {
+			return nil, snserrors.New(
+				ErrTypeMalformedJSON,
+				fmt.Sprintf("Duplicate key \"%s\" of \"%s\" in SNS message", key, decodedKey),
+			).WithCode(CodeDuplicateKey).WithDetails(snserrors.Details{Key: key})
+		}
+		decodedKeys[canonicalKey] = key
+
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, snserrors.Wrap(ErrTypeMalformedJSON, err, "")
+		}
+		value, isString := token.(string)
+		if !isString {
+			return nil, snserrors.New(
+				ErrTypeMalformedJSON,
+				fmt.Sprintf("\"%s\" must be a string in SNS message", key),
+			).WithCode(CodeNonStringValue).WithDetails(snserrors.Details{Key: key})
+		}
+		message.model().SetField(canonicalKey, value)
+	}
+
+	// Consume the closing brace and make sure nothing follows the object
+	if _, err := decoder.Token(); err != nil {
+		return nil, snserrors.Wrap(ErrTypeMalformedJSON, err, "")
 	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, snserrors.New(ErrTypeMalformedJSON, "Unexpected data after SNS message")
+	}
+
+	return message, nil
+}
+
+// UnmarshalJSON decodes the JSON-encoded SNS message like
+// snsmodel.Message.UnmarshalJSON does.
+func (message *SNSMessage) UnmarshalJSON(encoded []byte) error {
+	return message.model().UnmarshalJSON(encoded)
+}
+
+// MarshalJSON encodes the SNSMessage like snsmodel.Message.MarshalJSON does.
+func (message SNSMessage) MarshalJSON() ([]byte, error) {
+	return message.model().MarshalJSON()
+}
+
+// Field returns the value of the key of the SNSMessage and whether the key is
+// present, like snsmodel.Message.Field does.
+func (message *SNSMessage) Field(key string) (string, bool) {
+	return message.model().Field(key)
+}
+
+// SelectProtocolMessage returns the message delivered to subscriptions of the
+// protocol from a message published with MessageStructure=json. The
+// structured message is a JSON object of messages keyed by protocol, the
+// message of "default" is delivered to protocols without their own message.
+// If the structured message is not a JSON object of strings or does not have
+// the "default" message, it returns SNSError of type ErrMalformedStructure
+func SelectProtocolMessage(structured string, protocol string) (string, error) {
+	var messages map[string]string
+	if err := json.Unmarshal([]byte(structured), &messages); err != nil {
+		return "", snserrors.Wrap(ErrTypeMalformedStructure, err, "")
+	}
+
+	defaultMessage, exists := messages[ProtocolDefault]
+	if !exists {
+		return "", snserrors.New(
+			ErrTypeMalformedStructure,
+			fmt.Sprintf("\"%s\" is required in structured message", ProtocolDefault),
+		)
+	}
+	if message, exists := messages[protocol]; exists {
+		return message, nil
+	}
+	return defaultMessage, nil
+}
+
+// ForProtocol returns a copy of the SNSMessage published with
+// MessageStructure=json, with its "Message" replaced by the message SNS
+// delivers to subscriptions of the protocol.
+// The signature of the SNSMessage does not match the new "Message", so
+// "Signature" of the copy is cleared and has to be signed again.
+// If the "Message" is not a valid structured message, it returns SNSError of
+// type ErrMalformedStructure
+func (message *SNSMessage) ForProtocol(protocol string) (*SNSMessage, error) {
+	protocolMessage, err := SelectProtocolMessage(message.Message, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	delivered := *message
+	delivered.Message = protocolMessage
+	delivered.Signature = ""
+	validation.SetValidated(delivered.model(), false)
+	return &delivered, nil
 }
 
 // Get the SNSValidator of the SNSMessage
 func (message *SNSMessage) GetValidator() *snsvalidator.SNSValidator {
-	return snsvalidator.NewV1(message.toMap())
+	return snsvalidator.New(message)
+}
+
+// Validate validates the SNSMessage with its SNSValidator and marks the
+// SNSMessage as validated on success, so that its payload can be decoded by
+// DecodeValidatedMessage.
+// Modifying the SNSMessage after validation clears the validated mark.
+func (message *SNSMessage) Validate() error {
+	return message.ValidateContext(context.Background())
+}
+
+// ValidateContext is like Validate, but retrieves the signing certificate
+// with the given context.
+func (message *SNSMessage) ValidateContext(ctx context.Context) error {
+	if err := message.GetValidator().ValidateMessageContext(ctx); err != nil {
+		return err
+	}
+
+	validation.SetValidated(message.model(), true)
+	return nil
+}
+
+// VerifyWith validates the SNSMessage with the Verifier and marks the
+// SNSMessage as validated on success, like Validate does.
+func (message *SNSMessage) VerifyWith(ctx context.Context, verifier *snsvalidator.Verifier) error {
+	if err := verifier.Verify(ctx, message); err != nil {
+		return err
+	}
+
+	validation.SetValidated(message.model(), true)
+	return nil
+}
+
+// VerifyBatch validates the SNSMessages concurrently with the Verifier like
+// snsvalidator.Verifier.ValidateBatch does, and marks the valid SNSMessages
+// as validated like VerifyWith does.
+// It returns the errors of the SNSMessages in the order of the SNSMessages,
+// with nil for the valid SNSMessages.
+func VerifyBatch(ctx context.Context, verifier *snsvalidator.Verifier, messages []*SNSMessage) []error {
+	batch := make([]snsvalidator.Message, len(messages))
+	for i, message := range messages {
+		batch[i] = message
+	}
+
+	errs := verifier.ValidateBatch(ctx, batch)
+	for i, err := range errs {
+		if err == nil {
+			validation.SetValidated(messages[i].model(), true)
+		}
+	}
+	return errs
+}
+
+// IsValidated returns boolean on whether the SNSMessage has passed Validate()
+func (message *SNSMessage) IsValidated() bool {
+	return message.model().IsValidated()
+}
+
+// DecodeMessage decodes the JSON-encoded "Message" of the SNSMessage into a
+// value of type T. It does not require the SNSMessage to be validated, use
+// DecodeValidatedMessage to decode only validated payloads.
+// If the payload cannot be decoded into T, it returns SNSError of type
+// ErrMalformedPayload
+func DecodeMessage[T any](message *SNSMessage) (T, error) {
+	var payload T
+	if err := json.Unmarshal([]byte(message.Message), &payload); err != nil {
+		return payload, snserrors.Wrap(ErrTypeMalformedPayload, err, "")
+	}
+
+	return payload, nil
+}
+
+// DecodeValidatedMessage is like DecodeMessage, but refuses to decode the
+// payload of a SNSMessage that has not passed Validate().
+// If the SNSMessage is not validated, it returns SNSError of type
+// ErrNotValidated
+// If the payload cannot be decoded into T, it returns SNSError of type
+// ErrMalformedPayload
+func DecodeValidatedMessage[T any](message *SNSMessage) (T, error) {
+	if !message.IsValidated() {
+		var payload T
+		return payload, snserrors.New(
+			ErrTypeNotValidated,
+			"SNS message must be validated before decoding its payload",
+		)
+	}
+
+	return DecodeMessage[T](message)
+}
+
+// IsFIFO returns boolean on whether the SNSMessage is delivered from a FIFO
+// topic.
+func (message *SNSMessage) IsFIFO() bool {
+	return message.model().IsFIFO()
+}
+
+// CompareSequence compares the SequenceNumber of the SNSMessage with another
+// message from the same FIFO topic and message group. It returns -1 if the
+// message comes before the other message, 1 if it comes after and 0 if they
+// have the same SequenceNumber.
+// If the messages are not in the same message group or one of the
+// SequenceNumbers is invalid, it returns SNSError of type ErrInvalidSequence
+func (message *SNSMessage) CompareSequence(other *SNSMessage) (int, error) {
+	if message.TopicArn != other.TopicArn ||
+		message.MessageGroupId != other.MessageGroupId {
+		return 0, snserrors.New(
+			ErrTypeInvalidSequence,
+			"Messages of different message groups cannot be compared",
+		)
+	}
+
+	a, err := parseSequenceNumber(message.SequenceNumber)
+	if err != nil {
+		return 0, err
+	}
+	b, err := parseSequenceNumber(other.SequenceNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sequence numbers are up to 128 bits long and do not fit into any
+	// integer type. Without leading zeros a longer number is always larger
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1, nil
+		}
+		return 1, nil
+	}
+	return strings.Compare(a, b), nil
+}
+
+// parseSequenceNumber validates the SequenceNumber is a non-empty decimal
+// number and returns it with leading zeros trimmed.
+// If the SequenceNumber is invalid, it returns SNSError of type
+// ErrInvalidSequence
+func parseSequenceNumber(sequenceNumber string) (string, error) {
+	if sequenceNumber == "" {
+		return "", snserrors.New(ErrTypeInvalidSequence, "Missing SequenceNumber")
+	}
+	for _, c := range sequenceNumber {
+		if c < '0' || c > '9' {
+			return "", snserrors.New(
+				ErrTypeInvalidSequence,
+				fmt.Sprintf("Invalid SequenceNumber \"%s\"", sequenceNumber),
+			)
+		}
+	}
+	return strings.TrimLeft(sequenceNumber, "0"), nil
 }
@@ -1,10 +1,19 @@
 package snsmessage
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/h2non/gock.v1"
+
+	"github.com/yuhlau/go-sns-message-validator/internal/validation"
 	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmodel"
 	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
 )
 
@@ -22,6 +31,18 @@ var NotificationMessage = SNSMessage{
 	SigningCertURL:   "https://localhost/cert.pem",
 	UnsubscribeURL:   "https://localhost/unsubscribe",
 }
+var JSONNotificationMessage = SNSMessage{
+	Type:             "Notification",
+	MessageId:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+	TopicArn:         "arn:aws:sns:us-west-2:123456789012:MyTopic",
+	Message:          `{"id":1,"name":"test"}`,
+	Subject:          "Test subject",
+	Timestamp:        "2012-04-26T20:45:04.751Z",
+	SignatureVersion: "1",
+	Signature:        "Z2ZxqGoxh1zOankzqfvCMZlSHaWriMB8SlH36camvWEBpLvha2P5Y3nm1pCWW+OvomleeFeME6LMsCaysV5R8eESfmLvxQ5U5ETNVOSheEVfzVWxUTV6nSrgiq0OomOMyKGE2FbFGyhuARAvZSMKGLjvQraRqJ/Pb/y6wIYeLbU=",
+	SigningCertURL:   "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+	UnsubscribeURL:   "https://localhost/unsubscribe",
+}
 var SubscriptionMessage = SNSMessage{
 	Type:             "SubscriptionConfirmation",
 	MessageId:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
@@ -47,6 +68,37 @@ var UnsubscriptionMessage = SNSMessage{
 	SigningCertURL:   "https://localhost/cert.pem",
 }
 
+// Certificate of _assets/fakecert.pem which signs JSONNotificationMessage
+var certData = `-----BEGIN CERTIFICATE-----
+MIIC8zCCAlwCCQCLHrKJpPLt9TANBgkqhkiG9w0BAQsFADCBvDELMAkGA1UEBhMC
+SEsxEjAQBgNVBAgMCUhvbmctS29uZzESMBAGA1UEBwwJSG9uZy1Lb25nMSEwHwYD
+VQQKDBhnby1zbnMtbWVzc2FnZS12YWxpZGF0b3IxITAfBgNVBAsMGGdvLXNucy1t
+ZXNzYWdlLXZhbGlkYXRvcjEhMB8GA1UEAwwYZ28tc25zLW1lc3NhZ2UtdmFsaWRh
+dG9yMRwwGgYJKoZIhvcNAQkBFg1pYW1AeXVobGF1Lm1lMCAXDTE3MDkxNzE3MDA1
+MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
+bmctS29uZzESMBAGA1UEBwwJSG9uZy1Lb25nMSEwHwYDVQQKDBhnby1zbnMtbWVz
+c2FnZS12YWxpZGF0b3IxITAfBgNVBAsMGGdvLXNucy1tZXNzYWdlLXZhbGlkYXRv
+cjEhMB8GA1UEAwwYZ28tc25zLW1lc3NhZ2UtdmFsaWRhdG9yMRwwGgYJKoZIhvcN
+AQkBFg1pYW1AeXVobGF1Lm1lMIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQCu
+rgm/5MlF24ofyJNkNG/sjabX5d7i3ZQkJ6M8f+N9f1bF9ZRyGucODK8rFtHj/5Gc
+oDNCCduT/sqA0moDx0b1ChxO25srzNYRUe3cNHehpgEWtIzQJzrHpYUrqannmCgy
+JqcNJSLvN0Ex7WO6pMgx8xKXyDI2+Z9JhMHLvCAvMwIDAQABMA0GCSqGSIb3DQEB
+CwUAA4GBABUyTJZtvHmuOOSUZzaqE8HdwSzRMIGdLCQYZunBIb403Clf15f/+hpv
+vobi+xG4NkTmVX5kxRqwFb2C9OMtNaivC+nZKMo9WcNOQ9TqRSlIEJLrqP5dgrxn
+kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
+-----END CERTIFICATE-----
+`
+
+// decoded returns the message as decoded from the JSON of its non-empty keys
+func decoded(message SNSMessage) SNSMessage {
+	for _, key := range snsmodel.Keys {
+		if value, _ := message.Field(key); value != "" {
+			message.model().SetField(key, value)
+		}
+	}
+	return message
+}
+
 func TestNewFromJSON(t *testing.T) {
 	Convey("Given a valid JSON-encoded SNS message", t, func() {
 		encoded := []byte(`{
@@ -62,7 +114,7 @@ func TestNewFromJSON(t *testing.T) {
   "SigningCertURL": "https://localhost/cert.pem"
 }`)
 		Convey("It should succeeded and return SNSMessage representation of the message", func() {
-			expected := SubscriptionMessage
+			expected := decoded(SubscriptionMessage)
 			message, err := NewFromJSON(encoded)
 
 			So(*message, ShouldResemble, expected)
@@ -87,7 +139,7 @@ func TestNewFromJSON(t *testing.T) {
 			message, err := NewFromJSON(encoded)
 
 			So(message, ShouldBeNil)
-			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrMalformedJSON)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
 		})
 	})
 
@@ -112,41 +164,517 @@ func TestNewFromJSON(t *testing.T) {
 			So(message.SigningCertURL, ShouldEqual, "https://localhost/cert.pem")
 		})
 	})
+
+	Convey("Given a JSON-encoded SNS message from a FIFO topic", t, func() {
+		encoded := []byte(`{
+  "Type": "Notification",
+  "MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+  "SequenceNumber": "10000000000000000001",
+  "TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic.fifo",
+  "Message": "Test notification",
+  "MessageGroupId": "group-1",
+  "MessageDeduplicationId": "dedup-1",
+  "Timestamp": "2012-04-26T20:45:04.751Z",
+  "SignatureVersion": "1",
+  "Signature": "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
+  "SigningCertURL": "https://localhost/cert.pem"
+}`)
+
+		Convey("It should return SNSMessage with the FIFO fields", func() {
+			message, _ := NewFromJSON(encoded)
+
+			So(message.SequenceNumber, ShouldEqual, "10000000000000000001")
+			So(message.MessageGroupId, ShouldEqual, "group-1")
+			So(message.MessageDeduplicationId, ShouldEqual, "dedup-1")
+		})
+	})
+}
+
+// errReader is a reader failing with the error
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestNewFromReader(t *testing.T) {
+	encoded := `{
+  "Type": "SubscriptionConfirmation",
+  "MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+  "Token": "2336412f37fb687f5d51e6e241d09c805a5a",
+  "TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic",
+  "Message": "You have chosen to subscribe to the topic",
+  "SubscribeURL": "https://localhost/subscribe",
+  "Timestamp": "2012-04-26T20:45:04.751Z",
+  "SignatureVersion": "1",
+  "Signature": "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
+  "SigningCertURL": "https://localhost/cert.pem"
+}
+`
+
+	Convey("Given a reader of valid JSON-encoded SNS message", t, func() {
+		Convey("It should succeeded and return SNSMessage representation of the message", func() {
+			message, err := NewFromReader(strings.NewReader(encoded), 0)
+
+			So(*message, ShouldResemble, decoded(SubscriptionMessage))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("When the limit is exactly the length of the message", func() {
+			Convey("It should succeeded and return SNSMessage representation of the message", func() {
+				message, err := NewFromReader(strings.NewReader(encoded), int64(len(encoded)))
+
+				So(*message, ShouldResemble, decoded(SubscriptionMessage))
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When the limit is shorter than the message", func() {
+			Convey("It should return a SNSError of message too large", func() {
+				message, err := NewFromReader(strings.NewReader(encoded), int64(len(encoded)-1))
+
+				So(message, ShouldBeNil)
+				So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMessageTooLarge)
+			})
+		})
+	})
+
+	Convey("Given a reader of message longer than the default limit", t, func() {
+		encoded := `{"Message": "` + strings.Repeat("a", DefaultReadLimit) + `"}`
+
+		Convey("It should return a SNSError of message too large", func() {
+			message, err := NewFromReader(strings.NewReader(encoded), 0)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMessageTooLarge)
+		})
+	})
+
+	Convey("Given a reader of malformed JSON message", t, func() {
+		Convey("It should return a SNSError of malformed JSON", func() {
+			message, err := NewFromReader(strings.NewReader(`{"Type": "SubscriptionConfirmation`), 0)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+		})
+	})
+
+	Convey("Given a reader of JSON-encoded SNS message followed by another value", t, func() {
+		Convey("It should return a SNSError of malformed JSON", func() {
+			message, err := NewFromReader(strings.NewReader(encoded+encoded), 0)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+		})
+	})
+
+	Convey("Given a failing reader", t, func() {
+		Convey("It should return a SNSError of malformed JSON with the read error", func() {
+			message, err := NewFromReader(errReader{errors.New("connection reset")}, 0)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+			So(err.Error(), ShouldEqual, "connection reset")
+		})
+	})
+}
+
+func TestNewFromJSONStrict(t *testing.T) {
+	Convey("Given a valid JSON-encoded SNS message", t, func() {
+		encoded := []byte(`{
+  "Type": "SubscriptionConfirmation",
+  "MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+  "Token": "2336412f37fb687f5d51e6e241d09c805a5a",
+  "TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic",
+  "Message": "You have chosen to subscribe to the topic",
+  "SubscribeURL": "https://localhost/subscribe",
+  "Timestamp": "2012-04-26T20:45:04.751Z",
+  "SignatureVersion": "1",
+  "Signature": "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
+  "SigningCertURL": "https://localhost/cert.pem"
+}`)
+
+		Convey("It should succeeded and return SNSMessage representation of the message", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(*message, ShouldResemble, decoded(SubscriptionMessage))
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a JSON-encoded Lambda SNS message with \"SubscribeUrl\" and \"SigningCertUrl\"", t, func() {
+		encoded := []byte(`{
+  "Type": "SubscriptionConfirmation",
+  "MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+  "Token": "2336412f37fb687f5d51e6e241d09c805a5a",
+  "TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic",
+  "Message": "You have chosen to subscribe to the topic",
+  "SubscribeUrl": "https://localhost/subscribe",
+  "Timestamp": "2012-04-26T20:45:04.751Z",
+  "SignatureVersion": "1",
+  "Signature": "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
+  "SigningCertUrl": "https://localhost/cert.pem"
+}`)
+
+		Convey("It should succeeded and return SNSMessage representation of the message", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(*message, ShouldResemble, decoded(SubscriptionMessage))
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a JSON-encoded SNS message with both \"SigningCertURL\" and \"SigningCertUrl\"", t, func() {
+		encoded := []byte(`{
+  "Type": "Notification",
+  "SigningCertURL": "https://sns.us-west-2.amazonaws.com/cert.pem",
+  "SigningCertUrl": "https://localhost/cert.pem"
+}`)
+
+		Convey("It should return a SNSError of malformed JSON about the duplicate key", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+			So(err.Error(), ShouldEqual, `Duplicate key "SigningCertUrl" of "SigningCertURL" in SNS message`)
+			So(err.(*snserrors.SNSError).Code(), ShouldEqual, CodeDuplicateKey)
+			So(err.(*snserrors.SNSError).Details().Key, ShouldEqual, "SigningCertUrl")
+		})
+	})
+
+	Convey("Given a JSON-encoded SNS message with the same key twice", t, func() {
+		encoded := []byte(`{"Type": "Notification", "Type": "SubscriptionConfirmation"}`)
+
+		Convey("It should return a SNSError of malformed JSON about the duplicate key", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+			So(err.Error(), ShouldEqual, `Duplicate key "Type" of "Type" in SNS message`)
+		})
+	})
+
+	Convey("Given a JSON-encoded SNS message with a key in unexpected case", t, func() {
+		encoded := []byte(`{"type": "Notification"}`)
+
+		Convey("It should return a SNSError of malformed JSON about the unexpected key", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+			So(err.Error(), ShouldEqual, `Unexpected key "type" in SNS message`)
+			So(err.(*snserrors.SNSError).Code(), ShouldEqual, CodeUnexpectedKey)
+			So(err.(*snserrors.SNSError).Details().Key, ShouldEqual, "type")
+		})
+	})
+
+	Convey("Given a JSON-encoded SNS message with non-string value", t, func() {
+		encoded := []byte(`{"Type": "Notification", "SignatureVersion": 1}`)
+
+		Convey("It should return a SNSError of malformed JSON about the non-string value", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+			So(err.Error(), ShouldEqual, `"SignatureVersion" must be a string in SNS message`)
+			So(err.(*snserrors.SNSError).Code(), ShouldEqual, CodeNonStringValue)
+			So(err.(*snserrors.SNSError).Details().Key, ShouldEqual, "SignatureVersion")
+		})
+	})
+
+	Convey("Given a JSON-encoded SNS message with an object value", t, func() {
+		encoded := []byte(`{"Message": {"default": "Test notification"}}`)
+
+		Convey("It should return a SNSError of malformed JSON about the non-string value", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.Error(), ShouldEqual, `"Message" must be a string in SNS message`)
+		})
+	})
+
+	Convey("Given a JSON array", t, func() {
+		encoded := []byte(`["Notification"]`)
+
+		Convey("It should return a SNSError of malformed JSON", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+		})
+	})
+
+	Convey("Given a malformed JSON message", t, func() {
+		encoded := []byte(`{"Type": "SubscriptionConfirmation`)
+
+		Convey("It should return a SNSError of malformed JSON", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+		})
+	})
+
+	Convey("Given a JSON-encoded SNS message followed by another value", t, func() {
+		encoded := []byte(`{"Type": "Notification"} {"Type": "Notification"}`)
+
+		Convey("It should return a SNSError of malformed JSON", func() {
+			message, err := NewFromJSONStrict(encoded)
+
+			So(message, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedJSON)
+		})
+	})
+}
+
+func TestIsFIFOMethod(t *testing.T) {
+	Convey("Given a SNSMessage from a standard topic", t, func() {
+		message := NotificationMessage
+
+		Convey("It should return false", func() {
+			So(message.IsFIFO(), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a SNSMessage with SequenceNumber", t, func() {
+		message := NotificationMessage
+		message.SequenceNumber = "10000000000000000001"
+
+		Convey("It should return true", func() {
+			So(message.IsFIFO(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a SubscriptionConfirmation SNSMessage from a FIFO topic", t, func() {
+		message := SubscriptionMessage
+		message.TopicArn = "arn:aws:sns:us-west-2:123456789012:MyTopic.fifo"
+
+		Convey("It should return true", func() {
+			So(message.IsFIFO(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestCompareSequenceMethod(t *testing.T) {
+	newFIFOMessage := func(sequenceNumber string) *SNSMessage {
+		message := NotificationMessage
+		message.TopicArn = "arn:aws:sns:us-west-2:123456789012:MyTopic.fifo"
+		message.MessageGroupId = "group-1"
+		message.SequenceNumber = sequenceNumber
+		return &message
+	}
+
+	Convey("Given two SNSMessages of the same message group", t, func() {
+		first := newFIFOMessage("10000000000000000001")
+		second := newFIFOMessage("10000000000000000002")
+
+		Convey("It should return -1 when the message comes first", func() {
+			actual, err := first.CompareSequence(second)
+
+			So(actual, ShouldEqual, -1)
+			So(err, ShouldBeNil)
+		})
+		Convey("It should return 1 when the message comes later", func() {
+			actual, err := second.CompareSequence(first)
+
+			So(actual, ShouldEqual, 1)
+			So(err, ShouldBeNil)
+		})
+		Convey("It should return 0 when comparing with itself", func() {
+			actual, err := first.CompareSequence(first)
+
+			So(actual, ShouldEqual, 0)
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given two SNSMessages with SequenceNumbers of different length", t, func() {
+		first := newFIFOMessage("9")
+		second := newFIFOMessage("340282366920938463463374607431768211455")
+
+		Convey("It should compare the SequenceNumbers numerically", func() {
+			actual, _ := first.CompareSequence(second)
+
+			So(actual, ShouldEqual, -1)
+		})
+	})
+
+	Convey("Given two SNSMessages of different message groups", t, func() {
+		first := newFIFOMessage("10000000000000000001")
+		second := newFIFOMessage("10000000000000000002")
+		second.MessageGroupId = "group-2"
+
+		Convey("It should return a SNSError of invalid sequence", func() {
+			_, err := first.CompareSequence(second)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidSequence)
+		})
+	})
+
+	Convey("Given a SNSMessage with non-numeric SequenceNumber", t, func() {
+		first := newFIFOMessage("10000000000000000001")
+		second := newFIFOMessage("1e10")
+
+		Convey("It should return a SNSError of invalid sequence", func() {
+			_, err := first.CompareSequence(second)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidSequence)
+		})
+	})
+
+	Convey("Given a SNSMessage without SequenceNumber", t, func() {
+		first := newFIFOMessage("")
+		second := newFIFOMessage("10000000000000000001")
+
+		Convey("It should return a SNSError of invalid sequence", func() {
+			_, err := first.CompareSequence(second)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidSequence)
+		})
+	})
+}
+
+func TestSelectProtocolMessage(t *testing.T) {
+	structured := `{
+  "default": "Test notification",
+  "http": "{\"id\":1}",
+  "sqs": "Test notification for SQS"
+}`
+
+	Convey("Given a structured message", t, func() {
+		Convey("It should return the message of the protocol", func() {
+			actual, err := SelectProtocolMessage(structured, ProtocolHTTP)
+
+			So(actual, ShouldEqual, `{"id":1}`)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("It should return the default message for protocol without its own message", func() {
+			actual, err := SelectProtocolMessage(structured, ProtocolLambda)
+
+			So(actual, ShouldEqual, "Test notification")
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a structured message without the default message", t, func() {
+		Convey("It should return a SNSError of malformed structure", func() {
+			_, err := SelectProtocolMessage(`{"http": "Test notification"}`, ProtocolHTTP)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedStructure)
+			So(err.Error(), ShouldEqual, `"default" is required in structured message`)
+		})
+	})
+
+	Convey("Given a message which is not structured", t, func() {
+		Convey("It should return a SNSError of malformed structure", func() {
+			_, err := SelectProtocolMessage("Test notification", ProtocolHTTP)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedStructure)
+		})
+	})
+
+	Convey("Given a structured message with non-string message", t, func() {
+		Convey("It should return a SNSError of malformed structure", func() {
+			_, err := SelectProtocolMessage(`{"default": {"id": 1}}`, ProtocolHTTP)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedStructure)
+		})
+	})
 }
 
-func TestToMapMethod(t *testing.T) {
+func TestForProtocolMethod(t *testing.T) {
+	Convey("Given a validated SNSMessage published with MessageStructure=json", t, func() {
+		message := NotificationMessage
+		message.Message = `{"default": "Test notification", "https": "Test notification for HTTPS"}`
+		validation.SetValidated(message.model(), true)
+
+		Convey("It should return a copy with the message of the protocol", func() {
+			delivered, err := message.ForProtocol(ProtocolHTTPS)
+
+			So(err, ShouldBeNil)
+			So(delivered.Message, ShouldEqual, "Test notification for HTTPS")
+			So(delivered.MessageId, ShouldEqual, message.MessageId)
+
+			Convey("The copy should need to be signed and validated again", func() {
+				So(delivered.Signature, ShouldBeEmpty)
+				So(delivered.IsValidated(), ShouldBeFalse)
+			})
+			Convey("The original SNSMessage should be untouched", func() {
+				So(message.Message, ShouldStartWith, "{")
+				So(message.Signature, ShouldEqual, NotificationMessage.Signature)
+			})
+		})
+	})
+
+	Convey("Given a SNSMessage not published with MessageStructure=json", t, func() {
+		message := NotificationMessage
+
+		Convey("It should return a SNSError of malformed structure", func() {
+			delivered, err := message.ForProtocol(ProtocolHTTPS)
+
+			So(delivered, ShouldBeNil)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedStructure)
+		})
+	})
+}
+
+func TestFieldMethod(t *testing.T) {
 	Convey("Given a SNSMessage structure", t, func() {
 		message := SNSMessage{
-			Type:             "SubscriptionConfirmation",
-			MessageId:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
-			Token:            "2336412f37fb687f5d51e6e241d09c805a5a",
-			TopicArn:         "arn:aws:sns:us-west-2:123456789012:MyTopic",
-			Message:          "You have chosen to subscribe to the topic",
-			SubscribeURL:     "https://localhost/subscribe",
-			Timestamp:        "2012-04-26T20:45:04.751Z",
-			SignatureVersion: "1",
-			Signature:        "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
-			SigningCertURL:   "https://localhost/cert.pem",
+			Type:           "Notification",
+			Message:        "Test notification",
+			SigningCertURL: "https://localhost/cert.pem",
 		}
 
-		Convey("It should return map reprsentation of the SNSMessage", func() {
-			expected := map[string]string{
-				"Type":             "SubscriptionConfirmation",
-				"MessageId":        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
-				"Token":            "2336412f37fb687f5d51e6e241d09c805a5a",
-				"TopicArn":         "arn:aws:sns:us-west-2:123456789012:MyTopic",
-				"Message":          "You have chosen to subscribe to the topic",
-				"Subject":          "",
-				"SubscribeURL":     "https://localhost/subscribe",
-				"Timestamp":        "2012-04-26T20:45:04.751Z",
-				"SignatureVersion": "1",
-				"Signature":        "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
-				"SigningCertURL":   "https://localhost/cert.pem",
-				"UnsubscribeURL":   "",
-			}
-			actual := message.toMap()
+		Convey("It should return the value of the key as present", func() {
+			value, present := message.Field("SigningCertURL")
 
-			So(actual, ShouldResemble, expected)
+			So(value, ShouldEqual, "https://localhost/cert.pem")
+			So(present, ShouldBeTrue)
+		})
+
+		Convey("It should return the empty key as absent", func() {
+			_, present := message.Field("Subject")
+
+			So(present, ShouldBeFalse)
+		})
+
+		Convey("It should return unknown keys as absent", func() {
+			_, present := message.Field("Unknown")
+
+			So(present, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a SNSMessage decoded from JSON with an empty subject", t, func() {
+		message, err := NewFromJSON([]byte(`{"Type": "Notification", "Message": "Test notification", "Subject": ""}`))
+		So(err, ShouldBeNil)
+
+		Convey("It should return the empty subject as present", func() {
+			value, present := message.Field("Subject")
+
+			So(value, ShouldBeEmpty)
+			So(present, ShouldBeTrue)
+		})
+
+		Convey("It should return the keys absent from the JSON as absent", func() {
+			_, present := message.Field("UnsubscribeURL")
+
+			So(present, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a SNSMessage strictly decoded from JSON with an empty subject", t, func() {
+		message, err := NewFromJSONStrict([]byte(`{"Type": "Notification", "Message": "Test notification", "Subject": ""}`))
+		So(err, ShouldBeNil)
+
+		Convey("It should return the empty subject as present", func() {
+			_, present := message.Field("Subject")
+
+			So(present, ShouldBeTrue)
 		})
 	})
 }
@@ -174,21 +702,229 @@ func TestGetValidatorMethod(t *testing.T) {
 			Convey("Returned SNSValidator should be version 1", func() {
 				So(actual.Version, ShouldEqual, 1)
 			})
-			Convey("Returned SNSValidator should have a MessageMap of the original message", func() {
-				So(actual.MessageMap, ShouldResemble, map[string]string{
-					"Type":             "Notification",
-					"MessageId":        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
-					"Token":            "2336412f37fb687f5d51e6e241d09c805a5a",
-					"TopicArn":         "arn:aws:sns:us-west-2:123456789012:MyTopic",
-					"Message":          "Test notification",
-					"Subject":          "Test subject",
-					"SubscribeURL":     "",
-					"Timestamp":        "2012-04-26T20:45:04.751Z",
-					"SignatureVersion": "1",
-					"Signature":        "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
-					"SigningCertURL":   "https://localhost/cert.pem",
-					"UnsubscribeURL":   "https://localhost/unsubscribe",
-				})
+			Convey("Returned SNSValidator should validate the original message", func() {
+				So(actual.Message, ShouldEqual, &message)
+			})
+		})
+	})
+
+	Convey("Given a SNSMessage decoded from JSON with an empty subject", t, func() {
+		message, _ := NewFromJSON([]byte(`{"Type": "Notification", "MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b", "TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic", "Message": "Test notification", "Subject": "", "Timestamp": "2012-04-26T20:45:04.751Z"}`))
+
+		Convey("Returned SNSValidator should sign the empty subject", func() {
+			signable := string(message.GetValidator().SignableString())
+
+			So(signable, ShouldContainSubstring, "Subject\n\n")
+		})
+	})
+}
+
+func TestValidateMethod(t *testing.T) {
+	Convey("Given a SNSMessage with incorrect signature", t, func() {
+		message := NotificationMessage
+
+		Convey("It should return error and leave the SNSMessage not validated", func() {
+			err := message.Validate()
+
+			So(err, ShouldNotBeNil)
+			So(message.IsValidated(), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a SNSMessage with valid signature", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			BodyString(certData)
+		message := JSONNotificationMessage
+
+		Convey("It should return nil and mark the SNSMessage validated", func() {
+			err := message.Validate()
+
+			So(err, ShouldBeNil)
+			So(message.IsValidated(), ShouldBeTrue)
+		})
+	})
+}
+
+type testPayload struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestVerifyWithMethod(t *testing.T) {
+	Convey("Given a SNSMessage with valid signature and a Verifier", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			BodyString(certData)
+		message := JSONNotificationMessage
+		verifier := snsvalidator.NewVerifier()
+
+		Convey("It should return nil and mark the SNSMessage validated", func() {
+			err := message.VerifyWith(context.Background(), verifier)
+
+			So(err, ShouldBeNil)
+			So(message.IsValidated(), ShouldBeTrue)
+		})
+
+		Convey("When the SNSMessage is modified", func() {
+			message.Message = `{"id":2}`
+
+			Convey("It should return an error and leave the SNSMessage not validated", func() {
+				err := message.VerifyWith(context.Background(), verifier)
+
+				So(errors.Is(err, snsvalidator.ErrIncorrectSignature), ShouldBeTrue)
+				So(message.IsValidated(), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestVerifyBatch(t *testing.T) {
+	Convey("Given a batch of SNSMessages with an invalid SNSMessage and a Verifier", t, func() {
+		defer gock.Off()
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			BodyString(certData)
+		valid := JSONNotificationMessage
+		invalid := JSONNotificationMessage
+		invalid.Message = `{"id":2}`
+		verifier := snsvalidator.NewVerifier()
+
+		Convey("It should return the errors in order and mark the valid SNSMessages validated", func() {
+			errs := VerifyBatch(context.Background(), verifier, []*SNSMessage{&valid, &invalid})
+
+			So(errs[0], ShouldBeNil)
+			So(errors.Is(errs[1], snsvalidator.ErrIncorrectSignature), ShouldBeTrue)
+			So(valid.IsValidated(), ShouldBeTrue)
+			So(invalid.IsValidated(), ShouldBeFalse)
+		})
+	})
+}
+
+func BenchmarkNewFromJSON(b *testing.B) {
+	body, _ := json.Marshal(JSONNotificationMessage)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewFromJSON(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkNewFromReader compares NewFromReader with json.Decoder decoding
+// a SNS message close to MaxMessageSize, which json.Decoder holds both
+// encoded in its buffer and decoded
+func BenchmarkNewFromReader(b *testing.B) {
+	message := JSONNotificationMessage
+	message.Message = strings.Repeat("Long notification ", MaxMessageSize/20)
+	body, _ := json.Marshal(message)
+
+	b.Run("json.Decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := json.NewDecoder(bytes.NewReader(body)).Decode(&SNSMessage{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("NewFromReader", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewFromReader(bytes.NewReader(body), 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkVerifyWith benchmarks the full validation path of a request body,
+// with the signing certificate in the certificate cache of the Verifier
+func BenchmarkVerifyWith(b *testing.B) {
+	defer gock.Off()
+	gock.New("https://sns.ap-northeast-1.amazonaws.com").
+		Get("cert.pem").
+		Reply(200).
+		BodyString(certData)
+	body, _ := json.Marshal(JSONNotificationMessage)
+	verifier := snsvalidator.NewVerifier()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		message, err := NewFromJSON(body)
+		if err == nil {
+			err = message.VerifyWith(context.Background(), verifier)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	Convey("Given a SNSMessage with JSON-encoded payload", t, func() {
+		message := JSONNotificationMessage
+
+		Convey("It should decode the payload into the given type", func() {
+			payload, err := DecodeMessage[testPayload](&message)
+
+			So(payload, ShouldResemble, testPayload{Id: 1, Name: "test"})
+			So(err, ShouldBeNil)
+		})
+	})
+
+	Convey("Given a SNSMessage with non-JSON payload", t, func() {
+		message := NotificationMessage
+
+		Convey("It should return a SNSError of malformed payload", func() {
+			_, err := DecodeMessage[testPayload](&message)
+
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMalformedPayload)
+		})
+	})
+}
+
+func TestDecodeValidatedMessage(t *testing.T) {
+	Convey("Given a SNSMessage not validated", t, func() {
+		message := JSONNotificationMessage
+
+		Convey("It should return a SNSError of not validated", func() {
+			payload, err := DecodeValidatedMessage[testPayload](&message)
+
+			So(payload, ShouldBeZeroValue)
+			So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeNotValidated)
+		})
+	})
+
+	Convey("Given a validated SNSMessage", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			BodyString(certData)
+		message := JSONNotificationMessage
+		So(message.Validate(), ShouldBeNil)
+
+		Convey("It should decode the payload into the given type", func() {
+			payload, err := DecodeValidatedMessage[testPayload](&message)
+
+			So(payload, ShouldResemble, testPayload{Id: 1, Name: "test"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("When the payload is modified after validation", func() {
+			message.Message = `{"id":2,"name":"forged"}`
+
+			Convey("It should refuse to decode it", func() {
+				payload, err := DecodeValidatedMessage[testPayload](&message)
+
+				So(payload, ShouldBeZeroValue)
+				So(err.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeNotValidated)
 			})
 		})
 	})
@@ -0,0 +1,145 @@
+// Package snsmetrics adapts the snsvalidator.Observer to metrics in the
+// style of Prometheus, such as counters and histograms with labels. It only
+// declares the interfaces of the metrics, so the module does not depend on any
+// metrics library. A Prometheus vector is adapted with a function, e.g.
+//
+//	snsmetrics.CounterFunc(func(labelValues ...string) {
+//		counterVec.WithLabelValues(labelValues...).Inc()
+//	})
+package snsmetrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Label names of the metrics, in the order of the label values
+const (
+	// LabelOutcome is the outcome of a validation or a certificate retrieval,
+	// see snserrors.Outcome
+	LabelOutcome = "outcome"
+	// LabelResult is the result of a certificate cache lookup, ResultHit or
+	// ResultMiss
+	LabelResult = "result"
+	// LabelStatus is the HTTP status code of a certificate retrieval, e.g.
+	// "200", or StatusNone if there is no response
+	LabelStatus = "status"
+)
+
+// StatusNone is the LabelStatus of a certificate retrieval without response
+const StatusNone = "none"
+
+// Results of a certificate cache lookup
+const (
+	ResultHit  = "hit"
+	ResultMiss = "miss"
+)
+
+// Counter counts the events with the label values
+type Counter interface {
+	Inc(labelValues ...string)
+}
+
+// CounterFunc is a Counter of a function
+type CounterFunc func(labelValues ...string)
+
+func (f CounterFunc) Inc(labelValues ...string) {
+	f(labelValues...)
+}
+
+// Gauge tracks a value which goes up and down
+type Gauge interface {
+	Add(delta float64)
+}
+
+// GaugeFunc is a Gauge of a function
+type GaugeFunc func(delta float64)
+
+func (f GaugeFunc) Add(delta float64) {
+	f(delta)
+}
+
+// Histogram observes the distribution of the values with the label values
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// HistogramFunc is a Histogram of a function
+type HistogramFunc func(value float64, labelValues ...string)
+
+func (f HistogramFunc) Observe(value float64, labelValues ...string) {
+	f(value, labelValues...)
+}
+
+// Observer is a snsvalidator.Observer recording the metrics. The metrics
+// left nil are not recorded. Durations are observed in seconds.
+type Observer struct {
+	// Validations counts the finished validations by LabelOutcome
+	Validations Counter
+	// ValidationsInFlight tracks the validations in progress
+	ValidationsInFlight Gauge
+	// ValidationDuration observes the durations of the validations by
+	// LabelOutcome
+	ValidationDuration Histogram
+	// CertCacheLookups counts the certificate cache lookups by LabelResult
+	CertCacheLookups Counter
+	// CertFetches counts the certificate retrievals by LabelOutcome and
+	// LabelStatus
+	CertFetches Counter
+	// CertFetchDuration observes the durations of the certificate retrievals
+	// by LabelOutcome and LabelStatus
+	CertFetchDuration Histogram
+}
+
+var _ snsvalidator.Observer = (*Observer)(nil)
+
+func (observer *Observer) ValidationStarted(ctx context.Context) {
+	if observer.ValidationsInFlight != nil {
+		observer.ValidationsInFlight.Add(1)
+	}
+}
+
+func (observer *Observer) ValidationFinished(ctx context.Context, outcome string, duration time.Duration) {
+	if observer.ValidationsInFlight != nil {
+		observer.ValidationsInFlight.Add(-1)
+	}
+	if observer.Validations != nil {
+		observer.Validations.Inc(outcome)
+	}
+	if observer.ValidationDuration != nil {
+		observer.ValidationDuration.Observe(duration.Seconds(), outcome)
+	}
+}
+
+func (observer *Observer) CertCacheLookup(ctx context.Context, certURL string, hit bool) {
+	if observer.CertCacheLookups == nil {
+		return
+	}
+
+	if hit {
+		observer.CertCacheLookups.Inc(ResultHit)
+	} else {
+		observer.CertCacheLookups.Inc(ResultMiss)
+	}
+}
+
+func (observer *Observer) CertFetched(
+	ctx context.Context, certURL string, statusCode int, duration time.Duration, err error,
+) {
+	outcome := snserrors.Outcome(err)
+	status := StatusNone
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+
+	if observer.CertFetches != nil {
+		observer.CertFetches.Inc(outcome, status)
+	}
+	if observer.CertFetchDuration != nil {
+		observer.CertFetchDuration.Observe(duration.Seconds(), outcome, status)
+	}
+}
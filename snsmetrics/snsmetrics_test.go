@@ -0,0 +1,147 @@
+package snsmetrics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// metric records the values by the joined label values
+type metric struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newMetric() *metric {
+	return &metric{values: make(map[string]float64)}
+}
+
+func (m *metric) add(value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[strings.Join(labelValues, ",")] += value
+}
+
+func (m *metric) counter() Counter {
+	return CounterFunc(func(labelValues ...string) { m.add(1, labelValues...) })
+}
+
+func (m *metric) gauge() Gauge {
+	return GaugeFunc(func(delta float64) { m.add(delta) })
+}
+
+func (m *metric) histogram() Histogram {
+	return HistogramFunc(func(value float64, labelValues ...string) { m.add(1, labelValues...) })
+}
+
+func TestObserver(t *testing.T) {
+	Convey("Given an Observer with all metrics", t, func() {
+		validations, inFlight, validationDuration := newMetric(), newMetric(), newMetric()
+		lookups, fetches, fetchDuration := newMetric(), newMetric(), newMetric()
+		observer := &Observer{
+			Validations:         validations.counter(),
+			ValidationsInFlight: inFlight.gauge(),
+			ValidationDuration:  validationDuration.histogram(),
+			CertCacheLookups:    lookups.counter(),
+			CertFetches:         fetches.counter(),
+			CertFetchDuration:   fetchDuration.histogram(),
+		}
+		ctx := context.Background()
+
+		Convey("It should record the validations by outcome", func() {
+			observer.ValidationStarted(ctx)
+			observer.ValidationStarted(ctx)
+			So(inFlight.values[""], ShouldEqual, 2)
+
+			observer.ValidationFinished(ctx, snserrors.OutcomeValid, time.Millisecond)
+			observer.ValidationFinished(ctx, snsvalidator.ErrTypeIncorrectSignature, time.Millisecond)
+
+			So(inFlight.values[""], ShouldEqual, 0)
+			So(validations.values, ShouldResemble, map[string]float64{"Valid": 1, "IncorrectSignature": 1})
+			So(validationDuration.values, ShouldResemble, map[string]float64{"Valid": 1, "IncorrectSignature": 1})
+		})
+
+		Convey("It should record the certificate cache lookups by result", func() {
+			observer.CertCacheLookup(ctx, "https://localhost/cert.pem", false)
+			observer.CertCacheLookup(ctx, "https://localhost/cert.pem", true)
+			observer.CertCacheLookup(ctx, "https://localhost/cert.pem", true)
+
+			So(lookups.values, ShouldResemble, map[string]float64{"miss": 1, "hit": 2})
+		})
+
+		Convey("It should record the certificate retrievals by outcome and status", func() {
+			observer.CertFetched(ctx, "https://localhost/cert.pem", 200, time.Millisecond, nil)
+			observer.CertFetched(ctx, "https://localhost/cert.pem", 503, time.Millisecond, snsvalidator.ErrInvalidCert)
+			observer.CertFetched(ctx, "https://localhost/cert.pem", 0, time.Millisecond, errors.New("Failed"))
+
+			expected := map[string]float64{"Valid,200": 1, "InvalidCert,503": 1, "Unknown,none": 1}
+			So(fetches.values, ShouldResemble, expected)
+			So(fetchDuration.values, ShouldResemble, expected)
+		})
+	})
+
+	Convey("Given an Observer without metrics", t, func() {
+		observer := &Observer{}
+		ctx := context.Background()
+
+		Convey("It should ignore the events", func() {
+			So(func() {
+				observer.ValidationStarted(ctx)
+				observer.ValidationFinished(ctx, snserrors.OutcomeValid, time.Millisecond)
+				observer.CertCacheLookup(ctx, "https://localhost/cert.pem", true)
+				observer.CertFetched(ctx, "https://localhost/cert.pem", 200, time.Millisecond, nil)
+			}, ShouldNotPanic)
+		})
+	})
+}
+
+func TestObserverWithVerifier(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier observed by an Observer", t, func() {
+		validations, lookups := newMetric(), newMetric()
+		verifier := snsvalidator.NewVerifier(
+			snsvalidator.WithCertFetcher(snsvalidator.DirCertFetcher(dir)),
+			snsvalidator.WithObserver(&Observer{
+				Validations:      validations.counter(),
+				CertCacheLookups: lookups.counter(),
+			}),
+		)
+
+		Convey("It should record the validations of the messages", func() {
+			message := snsmessage.SNSMessage{
+				Type:             "Notification",
+				MessageId:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+				TopicArn:         "arn:aws:sns:us-west-2:123456789012:MyTopic",
+				Message:          "Test notification",
+				Subject:          "Test subject",
+				Timestamp:        "2012-04-26T20:45:04.751Z",
+				SignatureVersion: "1",
+				Signature:        "ol5x/KiU+7dWKRuyD6Y1EntwXo+orXlVgQbq4JDy5uh/+EBBz/mfWQ0X0LXyyxkXXCykDakEz1F0h9y9xV9UitLlYA/tEMzI7WU9ob9d9L8YTCZVaHZUtCu4S0p0eCFzT69q+ijPuH9N1znuZOzDogsJIf8E9/8owtRmi6M50Co=",
+				SigningCertURL:   "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+				UnsubscribeURL:   "https://localhost/unsubscribe",
+			}
+			forged := message
+			forged.Message = "Forged notification"
+
+			So(message.VerifyWith(context.Background(), verifier), ShouldBeNil)
+			forged.VerifyWith(context.Background(), verifier)
+
+			So(validations.values, ShouldResemble, map[string]float64{"Valid": 1, "IncorrectSignature": 1})
+			So(lookups.values, ShouldResemble, map[string]float64{"miss": 1, "hit": 1})
+		})
+	})
+}
@@ -0,0 +1,494 @@
+package snsmodel
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxDecodeDepth is the maximum nesting of the values of unknown keys, as in
+// encoding/json
+const maxDecodeDepth = 10000
+
+// DecodeJSON decodes the JSON-encoded SNS message read from the reader like
+// UnmarshalJSON, reading the reader to its end. Only whitespace may follow
+// the SNS message.
+// The values are unescaped from the read buffer into a reused scratch buffer
+// and allocated once as the strings of the fields, so that neither the
+// encoded message nor another copy of a value is held, only the fields. A
+// null value leaves the key absent, and the values of unknown keys are
+// skipped without being kept.
+func (message *Message) DecodeJSON(reader io.Reader) error {
+	decoder := decoderPool.Get().(*jsonDecoder)
+	decoder.reader.Reset(reader)
+	defer func() {
+		decoder.reader.Reset(nil)
+		decoder.scratch.Reset()
+		decoderPool.Put(decoder)
+	}()
+
+	message.validated = false
+	return decoder.decode(message)
+}
+
+// jsonDecoder decodes a JSON-encoded SNS message from a reader
+type jsonDecoder struct {
+	reader *bufio.Reader
+	// scratch is the unescaped string being decoded
+	scratch bytes.Buffer
+}
+
+var decoderPool = sync.Pool{
+	New: func() interface{} {
+		return &jsonDecoder{reader: bufio.NewReader(nil)}
+	},
+}
+
+// decode decodes the SNS message, and checks that only whitespace follows
+func (decoder *jsonDecoder) decode(message *Message) error {
+	c, err := decoder.next()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	switch c {
+	case '{':
+		err = decoder.decodeObject(message)
+	case 'n':
+		// Like UnmarshalJSON, null leaves the Message as is
+		err = decoder.literal("ull")
+	case '"', '[', 't', 'f', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		err = errors.New("SNS message is not a JSON object")
+	default:
+		err = syntaxError(c, "beginning of value")
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err = decoder.next()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return errors.New("Unexpected data after SNS message")
+}
+
+// decodeObject decodes the keys of the SNS message into the Message, the
+// opening brace being read
+func (decoder *jsonDecoder) decodeObject(message *Message) error {
+	c, err := decoder.next()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if c == '}' {
+		return nil
+	}
+
+	for {
+		if c != '"' {
+			return syntaxError(c, "beginning of object key string")
+		}
+		if err := decoder.unquote(); err != nil {
+			return err
+		}
+		key := decodedKey(decoder.scratch.String())
+		if err := decoder.expect(':', "after object key"); err != nil {
+			return err
+		}
+
+		if c, err = decoder.next(); err != nil {
+			return unexpectedEOF(err)
+		}
+		field, bit := message.fieldOf(key)
+		switch {
+		case field == nil:
+			err = decoder.skipValue(c, 1)
+		case c == '"':
+			if err = decoder.unquote(); err == nil {
+				*field = string(decoder.scratch.Bytes())
+				message.present |= bit
+			}
+		case c == 'n':
+			if err = decoder.literal("ull"); err == nil {
+				*field = ""
+				message.present &^= bit
+			}
+		default:
+			if err = decoder.skipValue(c, 1); err == nil {
+				err = fmt.Errorf("Value of SNS message key %s is not a string", key)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if c, err = decoder.next(); err != nil {
+			return unexpectedEOF(err)
+		}
+		switch c {
+		case '}':
+			return nil
+		case ',':
+		default:
+			return syntaxError(c, "after object key:value pair")
+		}
+		if c, err = decoder.next(); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+}
+
+// skipValue reads the JSON value starting with c without keeping it
+func (decoder *jsonDecoder) skipValue(c byte, depth int) error {
+	if depth > maxDecodeDepth {
+		return errors.New("Exceeded max depth of JSON value")
+	}
+
+	switch c {
+	case '"':
+		return decoder.unquote()
+	case '{':
+		return decoder.skipComposite('}', depth, func(c byte) error {
+			if c != '"' {
+				return syntaxError(c, "beginning of object key string")
+			}
+			if err := decoder.unquote(); err != nil {
+				return err
+			}
+			if err := decoder.expect(':', "after object key"); err != nil {
+				return err
+			}
+			c, err := decoder.next()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			return decoder.skipValue(c, depth+1)
+		})
+	case '[':
+		return decoder.skipComposite(']', depth, func(c byte) error {
+			return decoder.skipValue(c, depth+1)
+		})
+	case 't':
+		return decoder.literal("rue")
+	case 'f':
+		return decoder.literal("alse")
+	case 'n':
+		return decoder.literal("ull")
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return decoder.skipNumber(c)
+	}
+	return syntaxError(c, "beginning of value")
+}
+
+// skipComposite reads the elements of an object or an array, the opening
+// delimiter being read, with skipElement up to the closing delimiter
+func (decoder *jsonDecoder) skipComposite(closing byte, depth int, skipElement func(c byte) error) error {
+	c, err := decoder.next()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if c == closing {
+		return nil
+	}
+
+	for {
+		if err := skipElement(c); err != nil {
+			return err
+		}
+		if c, err = decoder.next(); err != nil {
+			return unexpectedEOF(err)
+		}
+		if c == closing {
+			return nil
+		}
+		if c != ',' {
+			return syntaxError(c, "after element")
+		}
+		if c, err = decoder.next(); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+}
+
+// skipNumber reads the JSON number starting with c
+func (decoder *jsonDecoder) skipNumber(c byte) error {
+	var err error
+	if c == '-' {
+		if c, err = decoder.reader.ReadByte(); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+	if c < '0' || c > '9' {
+		return syntaxError(c, "in numeric literal")
+	}
+	if c != '0' {
+		decoder.skipDigits()
+	}
+
+	if decoder.peek() == '.' {
+		decoder.reader.Discard(1)
+		if err := decoder.skipRequiredDigits(); err != nil {
+			return err
+		}
+	}
+	if next := decoder.peek(); next == 'e' || next == 'E' {
+		decoder.reader.Discard(1)
+		if next := decoder.peek(); next == '+' || next == '-' {
+			decoder.reader.Discard(1)
+		}
+		if err := decoder.skipRequiredDigits(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipRequiredDigits reads one or more digits
+func (decoder *jsonDecoder) skipRequiredDigits() error {
+	c, err := decoder.reader.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if c < '0' || c > '9' {
+		return syntaxError(c, "in numeric literal")
+	}
+	decoder.skipDigits()
+	return nil
+}
+
+// skipDigits reads the digits up to the next other byte
+func (decoder *jsonDecoder) skipDigits() {
+	for next := decoder.peek(); next >= '0' && next <= '9'; next = decoder.peek() {
+		decoder.reader.Discard(1)
+	}
+}
+
+// unquote unescapes the JSON string into the scratch buffer, the opening
+// quote being read. Invalid UTF-8 and invalid surrogates are replaced with
+// utf8.RuneError, as in encoding/json.
+func (decoder *jsonDecoder) unquote() error {
+	decoder.scratch.Reset()
+	for {
+		if _, err := decoder.reader.Peek(1); err != nil {
+			return unexpectedEOF(err)
+		}
+		buffered, _ := decoder.reader.Peek(decoder.reader.Buffered())
+
+		// Copy the run of characters which need no unescaping at once
+		i := 0
+		for i < len(buffered) {
+			c := buffered[i]
+			if c < utf8.RuneSelf {
+				if c < ' ' || c == '"' || c == '\\' {
+					break
+				}
+				i++
+				continue
+			}
+			r, size := utf8.DecodeRune(buffered[i:])
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			i += size
+		}
+		decoder.scratch.Write(buffered[:i])
+		decoder.reader.Discard(i)
+		if i == len(buffered) {
+			continue
+		}
+
+		c := buffered[i]
+		switch {
+		case c == '"':
+			decoder.reader.Discard(1)
+			return nil
+		case c == '\\':
+			decoder.reader.Discard(1)
+			if err := decoder.unescape(); err != nil {
+				return err
+			}
+		case c < ' ':
+			return syntaxError(c, "in string literal")
+		default:
+			// Either invalid UTF-8 or a rune cut at the end of the buffer
+			decoder.readRune()
+		}
+	}
+}
+
+// readRune copies the next rune into the scratch buffer, or utf8.RuneError
+// if it is invalid UTF-8
+func (decoder *jsonDecoder) readRune() {
+	next, _ := decoder.reader.Peek(utf8.UTFMax)
+	r, size := utf8.DecodeRune(next)
+	if r == utf8.RuneError && size == 1 {
+		decoder.scratch.WriteRune(utf8.RuneError)
+	} else {
+		decoder.scratch.Write(next[:size])
+	}
+	decoder.reader.Discard(size)
+}
+
+// unescape unescapes the escape sequence into the scratch buffer, the
+// backslash being read
+func (decoder *jsonDecoder) unescape() error {
+	c, err := decoder.reader.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	switch c {
+	case '"', '\\', '/':
+		decoder.scratch.WriteByte(c)
+	case 'b':
+		decoder.scratch.WriteByte('\b')
+	case 'f':
+		decoder.scratch.WriteByte('\f')
+	case 'n':
+		decoder.scratch.WriteByte('\n')
+	case 'r':
+		decoder.scratch.WriteByte('\r')
+	case 't':
+		decoder.scratch.WriteByte('\t')
+	case 'u':
+		r, err := decoder.readHex()
+		if err != nil {
+			return err
+		}
+		if utf16.IsSurrogate(r) {
+			// The second half of a surrogate pair is another \uXXXX escape.
+			// It is left to be read as is if it does not complete the pair
+			pair := unicode.ReplacementChar
+			if next, _ := decoder.reader.Peek(6); len(next) == 6 && next[0] == '\\' && next[1] == 'u' {
+				if r2, ok := parseHex(next[2:]); ok {
+					if pair = utf16.DecodeRune(r, r2); pair != unicode.ReplacementChar {
+						decoder.reader.Discard(6)
+					}
+				}
+			}
+			r = pair
+		}
+		decoder.scratch.WriteRune(r)
+	default:
+		return syntaxError(c, "in string escape code")
+	}
+	return nil
+}
+
+// readHex reads the 4 hexadecimal digits of a \u escape
+func (decoder *jsonDecoder) readHex() (rune, error) {
+	var digits [4]byte
+	if _, err := io.ReadFull(decoder.reader, digits[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	r, ok := parseHex(digits[:])
+	if !ok {
+		return 0, errors.New("Invalid \\u escape in string literal")
+	}
+	return r, nil
+}
+
+// parseHex parses the 4 hexadecimal digits of a \u escape
+func parseHex(digits []byte) (rune, bool) {
+	var r rune
+	for _, c := range digits[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r*16 + rune(c)
+	}
+	return r, true
+}
+
+// literal reads the rest of the literal, its first byte being read
+func (decoder *jsonDecoder) literal(rest string) error {
+	for i := 0; i < len(rest); i++ {
+		c, err := decoder.reader.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if c != rest[i] {
+			return syntaxError(c, "in literal")
+		}
+	}
+	return nil
+}
+
+// expect reads the next byte other than whitespace, which must be c
+func (decoder *jsonDecoder) expect(c byte, context string) error {
+	next, err := decoder.next()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if next != c {
+		return syntaxError(next, context)
+	}
+	return nil
+}
+
+// next reads the next byte other than whitespace
+func (decoder *jsonDecoder) next() (byte, error) {
+	for {
+		c, err := decoder.reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c, nil
+		}
+	}
+}
+
+// peek returns the next byte without reading it, or 0 if there is none
+func (decoder *jsonDecoder) peek() byte {
+	next, err := decoder.reader.Peek(1)
+	if err != nil {
+		return 0
+	}
+	return next[0]
+}
+
+// decodedKey returns the SNS message key of the JSON key like json.Unmarshal
+// matches the fields, first exactly and then case-insensitively, or "" if the
+// JSON key is not a SNS message key.
+func decodedKey(key string) string {
+	var message Message
+	if field, _ := message.fieldOf(key); field != nil {
+		return key
+	}
+	for _, k := range Keys {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return ""
+}
+
+// syntaxError returns the error of the unexpected byte c
+func syntaxError(c byte, context string) error {
+	return fmt.Errorf("Invalid character %q %s", c, context)
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF for io.EOF, which is unexpected
+// within a value, and err otherwise
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
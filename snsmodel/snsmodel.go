@@ -0,0 +1,345 @@
+// Package snsmodel is the model of SNS message shared by the parser and the
+// validator. It neither parses nor validates a SNS message on its own, so
+// both snsmessage and snsvalidator can depend on it.
+package snsmodel
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+
+	"github.com/yuhlau/go-sns-message-validator/internal/validation"
+)
+
+// Types of SNS message
+const (
+	TypeNotification             = "Notification"
+	TypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	TypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// Keys of SNS message
+const (
+	KeyType             = "Type"
+	KeyMessageId        = "MessageId"
+	KeyToken            = "Token"
+	KeyTopicArn         = "TopicArn"
+	KeyMessage          = "Message"
+	KeySubject          = "Subject"
+	KeySubscribeURL     = "SubscribeURL"
+	KeyTimestamp        = "Timestamp"
+	KeySignatureVersion = "SignatureVersion"
+	KeySignature        = "Signature"
+	KeySigningCertURL   = "SigningCertURL"
+	KeyUnsubscribeURL   = "UnsubscribeURL"
+
+	// FIFO topic keys
+	KeySequenceNumber         = "SequenceNumber"
+	KeyMessageGroupId         = "MessageGroupId"
+	KeyMessageDeduplicationId = "MessageDeduplicationId"
+)
+
+// Keys lists all keys of SNS message
+var Keys = []string{
+	KeyType,
+	KeyMessageId,
+	KeyToken,
+	KeyTopicArn,
+	KeyMessage,
+	KeySubject,
+	KeySubscribeURL,
+	KeyTimestamp,
+	KeySignatureVersion,
+	KeySignature,
+	KeySigningCertURL,
+	KeyUnsubscribeURL,
+	KeySequenceNumber,
+	KeyMessageGroupId,
+	KeyMessageDeduplicationId,
+}
+
+func init() {
+	validation.SetValidated = func(message interface{}, validated bool) {
+		message.(*Message).setValidated(validated)
+	}
+}
+
+// Message structure
+// This structure can serve both Lambda and server end point SNS Message.
+// SNS Message delivered to Lambda function will have its "*URL" fields end
+// with camelcase "Url" instead of all uppdercase "URL". Go json.Unmarshal()
+// will first try an exact match of struct field name of its tag, then accepts
+// a case-insensitive match.
+// SNS Message delivered from a FIFO topic also carries "SequenceNumber",
+// "MessageGroupId" and "MessageDeduplicationId".
+type Message struct {
+	Type             string `json:"Type"`
+	MessageId        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Message          string `json:"Message"`
+	Subject          string `json:"Subject"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	UnsubscribeURL   string `json:"UnsubscribeURL"`
+
+	// FIFO topic fields
+	SequenceNumber         string `json:"SequenceNumber"`
+	MessageGroupId         string `json:"MessageGroupId"`
+	MessageDeduplicationId string `json:"MessageDeduplicationId"`
+
+	// Whether the Message has passed validation. Only the packages of the
+	// module can mark a Message validated
+	validated bool
+	// Digest of the keys of the Message when it is marked validated, so that
+	// the Message modified afterwards is no longer validated
+	validatedDigest [sha256.Size]byte
+	// Keys present in the decoded JSON, even if empty
+	present fieldSet
+}
+
+// fieldSet is a set of the keys of Message, one bit per key
+type fieldSet uint16
+
+// Bits of the keys of Message in fieldSet
+const (
+	fieldType fieldSet = 1 << iota
+	fieldMessageId
+	fieldToken
+	fieldTopicArn
+	fieldMessage
+	fieldSubject
+	fieldSubscribeURL
+	fieldTimestamp
+	fieldSignatureVersion
+	fieldSignature
+	fieldSigningCertURL
+	fieldUnsubscribeURL
+	fieldSequenceNumber
+	fieldMessageGroupId
+	fieldMessageDeduplicationId
+)
+
+// jsonMessage is the JSON representation of Message. A nil field means the
+// key is absent
+type jsonMessage struct {
+	Type             *string `json:"Type,omitempty"`
+	MessageId        *string `json:"MessageId,omitempty"`
+	Token            *string `json:"Token,omitempty"`
+	TopicArn         *string `json:"TopicArn,omitempty"`
+	Message          *string `json:"Message,omitempty"`
+	Subject          *string `json:"Subject,omitempty"`
+	SubscribeURL     *string `json:"SubscribeURL,omitempty"`
+	Timestamp        *string `json:"Timestamp,omitempty"`
+	SignatureVersion *string `json:"SignatureVersion,omitempty"`
+	Signature        *string `json:"Signature,omitempty"`
+	SigningCertURL   *string `json:"SigningCertURL,omitempty"`
+	UnsubscribeURL   *string `json:"UnsubscribeURL,omitempty"`
+
+	SequenceNumber         *string `json:"SequenceNumber,omitempty"`
+	MessageGroupId         *string `json:"MessageGroupId,omitempty"`
+	MessageDeduplicationId *string `json:"MessageDeduplicationId,omitempty"`
+}
+
+// CanonicalKey returns the SNS message key of the JSON key and whether the
+// JSON key is a known SNS message key.
+// Only the exact key and the camelcase "*Url" variants of Lambda SNS message
+// are accepted, e.g. "SigningCertUrl" is "SigningCertURL".
+func CanonicalKey(key string) (string, bool) {
+	switch key {
+	case "SubscribeUrl":
+		return KeySubscribeURL, true
+	case "SigningCertUrl":
+		return KeySigningCertURL, true
+	case "UnsubscribeUrl":
+		return KeyUnsubscribeURL, true
+	}
+
+	var message Message
+	field, _ := message.fieldOf(key)
+	return key, field != nil
+}
+
+// fieldOf returns pointer to the field of the Message of the key and its bit
+// in fieldSet, or nil if the key is not a SNS message key.
+func (message *Message) fieldOf(key string) (*string, fieldSet) {
+	switch key {
+	case KeyType:
+		return &message.Type, fieldType
+	case KeyMessageId:
+		return &message.MessageId, fieldMessageId
+	case KeyToken:
+		return &message.Token, fieldToken
+	case KeyTopicArn:
+		return &message.TopicArn, fieldTopicArn
+	case KeyMessage:
+		return &message.Message, fieldMessage
+	case KeySubject:
+		return &message.Subject, fieldSubject
+	case KeySubscribeURL:
+		return &message.SubscribeURL, fieldSubscribeURL
+	case KeyTimestamp:
+		return &message.Timestamp, fieldTimestamp
+	case KeySignatureVersion:
+		return &message.SignatureVersion, fieldSignatureVersion
+	case KeySignature:
+		return &message.Signature, fieldSignature
+	case KeySigningCertURL:
+		return &message.SigningCertURL, fieldSigningCertURL
+	case KeyUnsubscribeURL:
+		return &message.UnsubscribeURL, fieldUnsubscribeURL
+	case KeySequenceNumber:
+		return &message.SequenceNumber, fieldSequenceNumber
+	case KeyMessageGroupId:
+		return &message.MessageGroupId, fieldMessageGroupId
+	case KeyMessageDeduplicationId:
+		return &message.MessageDeduplicationId, fieldMessageDeduplicationId
+	}
+	return nil, 0
+}
+
+// Field returns the value of the key of the Message and whether the key is
+// present, so that the Message can be validated by snsvalidator. A key is
+// present if it has a value or it is present in the decoded JSON, even if it
+// is empty. The key is one of the SNS message keys, e.g. "Subject".
+func (message *Message) Field(key string) (string, bool) {
+	field, bit := message.fieldOf(key)
+	if field == nil {
+		return "", false
+	}
+	return *field, *field != "" || message.present&bit != 0
+}
+
+// SetField sets the value of the key of the Message and marks the key
+// present, even if the value is empty. It returns false if the key is not a
+// SNS message key, see CanonicalKey for the accepted keys.
+func (message *Message) SetField(key string, value string) bool {
+	key, _ = CanonicalKey(key)
+	field, bit := message.fieldOf(key)
+	if field == nil {
+		return false
+	}
+
+	*field = value
+	message.present |= bit
+	message.validated = false
+	return true
+}
+
+// UnmarshalJSON decodes the JSON-encoded SNS message like json.Unmarshal
+// decodes into the fields, and records the keys present in the JSON so that
+// an empty but present key, such as an empty "Subject", is told apart from an
+// absent key.
+func (message *Message) UnmarshalJSON(encoded []byte) error {
+	message.validated = false
+
+	var decoded jsonMessage
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return err
+	}
+
+	message.set(KeyType, decoded.Type)
+	message.set(KeyMessageId, decoded.MessageId)
+	message.set(KeyToken, decoded.Token)
+	message.set(KeyTopicArn, decoded.TopicArn)
+	message.set(KeyMessage, decoded.Message)
+	message.set(KeySubject, decoded.Subject)
+	message.set(KeySubscribeURL, decoded.SubscribeURL)
+	message.set(KeyTimestamp, decoded.Timestamp)
+	message.set(KeySignatureVersion, decoded.SignatureVersion)
+	message.set(KeySignature, decoded.Signature)
+	message.set(KeySigningCertURL, decoded.SigningCertURL)
+	message.set(KeyUnsubscribeURL, decoded.UnsubscribeURL)
+	message.set(KeySequenceNumber, decoded.SequenceNumber)
+	message.set(KeyMessageGroupId, decoded.MessageGroupId)
+	message.set(KeyMessageDeduplicationId, decoded.MessageDeduplicationId)
+	return nil
+}
+
+// set sets the field of the key to the decoded value and marks the key
+// present, if the value is decoded
+func (message *Message) set(key string, value *string) {
+	if value != nil {
+		message.SetField(key, *value)
+	}
+}
+
+// MarshalJSON encodes the Message with the present keys only, so that the
+// decoded Message has the same keys present and the same signable string.
+func (message Message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMessage{
+		Type:             message.get(KeyType),
+		MessageId:        message.get(KeyMessageId),
+		Token:            message.get(KeyToken),
+		TopicArn:         message.get(KeyTopicArn),
+		Message:          message.get(KeyMessage),
+		Subject:          message.get(KeySubject),
+		SubscribeURL:     message.get(KeySubscribeURL),
+		Timestamp:        message.get(KeyTimestamp),
+		SignatureVersion: message.get(KeySignatureVersion),
+		Signature:        message.get(KeySignature),
+		SigningCertURL:   message.get(KeySigningCertURL),
+		UnsubscribeURL:   message.get(KeyUnsubscribeURL),
+
+		SequenceNumber:         message.get(KeySequenceNumber),
+		MessageGroupId:         message.get(KeyMessageGroupId),
+		MessageDeduplicationId: message.get(KeyMessageDeduplicationId),
+	})
+}
+
+// get returns the value of the key to encode, or nil if the key is absent
+func (message *Message) get(key string) *string {
+	if value, present := message.Field(key); present {
+		return &value
+	}
+	return nil
+}
+
+// IsValidated returns boolean on whether the Message has passed validation
+// and has not been modified since. Setting a field with SetField or decoding
+// JSON into the Message clears the flag, and so does any other change of the
+// fields, as the flag is bound to the digest of the fields at validation.
+func (message *Message) IsValidated() bool {
+	return message.validated && message.digest() == message.validatedDigest
+}
+
+// setValidated marks the Message validated or not, binding the flag to the
+// current fields
+func (message *Message) setValidated(validated bool) {
+	message.validated = validated
+	if validated {
+		message.validatedDigest = message.digest()
+	}
+}
+
+// digest returns the SHA256 digest of the keys of the Message, with their
+// presence and values
+func (message *Message) digest() [sha256.Size]byte {
+	hash := sha256.New()
+	var header [9]byte
+	for _, key := range Keys {
+		value, present := message.Field(key)
+		header[0] = 0
+		if present {
+			header[0] = 1
+		}
+		binary.BigEndian.PutUint64(header[1:], uint64(len(value)))
+		hash.Write(header[:])
+		hash.Write([]byte(value))
+	}
+
+	var digest [sha256.Size]byte
+	hash.Sum(digest[:0])
+	return digest
+}
+
+// IsFIFO returns boolean on whether the Message is delivered from a FIFO
+// topic.
+func (message *Message) IsFIFO() bool {
+	return message.SequenceNumber != "" ||
+		strings.HasSuffix(message.TopicArn, ".fifo")
+}
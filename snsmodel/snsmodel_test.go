@@ -0,0 +1,294 @@
+package snsmodel
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/yuhlau/go-sns-message-validator/internal/validation"
+)
+
+func TestCanonicalKey(t *testing.T) {
+	Convey("Given a SNS message key", t, func() {
+		Convey("It should return the key as known", func() {
+			key, known := CanonicalKey("SigningCertURL")
+
+			So(key, ShouldEqual, KeySigningCertURL)
+			So(known, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a camelcase \"*Url\" key of Lambda SNS message", t, func() {
+		Convey("It should return the SNS message key", func() {
+			key, known := CanonicalKey("SigningCertUrl")
+
+			So(key, ShouldEqual, KeySigningCertURL)
+			So(known, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an unknown key", t, func() {
+		Convey("It should return the key as unknown", func() {
+			_, known := CanonicalKey("signingcerturl")
+
+			So(known, ShouldBeFalse)
+		})
+	})
+}
+
+func TestFieldMethod(t *testing.T) {
+	Convey("Given a Message structure", t, func() {
+		message := Message{Type: "Notification", Message: "Test notification"}
+
+		Convey("It should return the key with value as present", func() {
+			value, present := message.Field(KeyType)
+
+			So(value, ShouldEqual, "Notification")
+			So(present, ShouldBeTrue)
+		})
+
+		Convey("It should return the empty key as absent", func() {
+			_, present := message.Field(KeySubject)
+
+			So(present, ShouldBeFalse)
+		})
+
+		Convey("It should return unknown keys as absent", func() {
+			_, present := message.Field("Unknown")
+
+			So(present, ShouldBeFalse)
+		})
+	})
+}
+
+func TestSetFieldMethod(t *testing.T) {
+	Convey("Given a Message", t, func() {
+		message := Message{}
+
+		Convey("It should set the empty value and mark the key present", func() {
+			So(message.SetField(KeySubject, ""), ShouldBeTrue)
+
+			_, present := message.Field(KeySubject)
+			So(present, ShouldBeTrue)
+		})
+
+		Convey("It should set the field of camelcase \"*Url\" key", func() {
+			So(message.SetField("SubscribeUrl", "https://localhost/subscribe"), ShouldBeTrue)
+			So(message.SubscribeURL, ShouldEqual, "https://localhost/subscribe")
+		})
+
+		Convey("It should reject unknown keys", func() {
+			So(message.SetField("Unknown", "value"), ShouldBeFalse)
+		})
+	})
+}
+
+func TestUnmarshalJSONMethod(t *testing.T) {
+	Convey("Given a JSON-encoded SNS message with an empty subject", t, func() {
+		encoded := []byte(`{"Type": "Notification", "Message": "Test notification", "Subject": "", "SigningCertUrl": "https://localhost/cert.pem"}`)
+
+		Convey("It should decode the fields", func() {
+			var message Message
+			err := json.Unmarshal(encoded, &message)
+
+			So(err, ShouldBeNil)
+			So(message.Type, ShouldEqual, "Notification")
+			So(message.SigningCertURL, ShouldEqual, "https://localhost/cert.pem")
+		})
+
+		Convey("It should decode the empty subject as present", func() {
+			var message Message
+			json.Unmarshal(encoded, &message)
+			_, present := message.Field(KeySubject)
+
+			So(present, ShouldBeTrue)
+		})
+	})
+}
+
+func TestDecodeJSONMethod(t *testing.T) {
+	Convey("Given JSON-encoded SNS messages", t, func() {
+		encodeds := []string{
+			`{"Type": "Notification", "Message": "Test notification", "Subject": "", "SigningCertUrl": "https://localhost/cert.pem"}`,
+			`{"type": "Notification", "MESSAGE": "Test notification", "unsubscribeurl": "https://localhost/unsubscribe"}`,
+			`{"Type": "Notification", "Extra": {"Nested": [1, "two", null, true, false, {}, []]}, "Count": -3.5e+2, "Message": "Test notification"}`,
+			`{"Subject": "Test subject", "Subject": null, "Message": "First", "Message": "Second"}`,
+			`{"Message": "Escaped \"quotes\" \\ \/ \b\f\n\r\t and \u00e9"}`,
+			`{"Message": "Surrogate pair \ud83d\ude00, lone \ud83d and \ude00, and \ud83d\u0041"}`,
+			"{\"Message\": \"Invalid UTF-8 \xff, cut \xe2\x82 and valid \xe2\x82\xac \u65e5\u672c\"}",
+			"{\"Mess\\u0061ge\": \"Escaped key\"}",
+			" \t\r\n{ \"Message\" : \"Whitespace\" } \n",
+			`{"Message": "` + strings.Repeat("Long notification ", 1000) + `"}`,
+			`{}`,
+			`null`,
+		}
+
+		Convey("It should decode them like UnmarshalJSON", func() {
+			for _, encoded := range encodeds {
+				var expected, actual Message
+				So(json.Unmarshal([]byte(encoded), &expected), ShouldBeNil)
+
+				So(actual.DecodeJSON(strings.NewReader(encoded)), ShouldBeNil)
+				So(actual, ShouldResemble, expected)
+			}
+		})
+	})
+
+	Convey("Given malformed JSON-encoded SNS messages", t, func() {
+		encodeds := []string{
+			``,
+			`{"Type": "Notification"`,
+			`{"Type": "Notification`,
+			`{"Type" "Notification"}`,
+			`{"Type": "Notification",}`,
+			`{"Type": 1}`,
+			`{"Type": "Notification", "Extra": [1, 2,]}`,
+			`{"Extra": 01}`,
+			`{"Extra": 1.}`,
+			`{"Extra": -}`,
+			`{"Extra": tru}`,
+			`{"Message": "\x"}`,
+			`{"Message": "\u00g0"}`,
+			"{\"Message\": \"Control \n character\"}",
+			`{"Extra": ` + strings.Repeat("[", maxDecodeDepth+1) + strings.Repeat("]", maxDecodeDepth+1) + `}`,
+			`["Type", "Notification"]`,
+			`"Notification"`,
+			`{"Type": "Notification"} {"Type": "Notification"}`,
+		}
+
+		Convey("It should fail like UnmarshalJSON", func() {
+			for _, encoded := range encodeds {
+				var expected, actual Message
+				So(json.Unmarshal([]byte(encoded), &expected), ShouldNotBeNil)
+
+				So(actual.DecodeJSON(strings.NewReader(encoded)), ShouldNotBeNil)
+			}
+		})
+	})
+
+	Convey("Given a JSON-encoded SNS message read a byte at a time", t, func() {
+		encoded := "{\"Message\": \"Split \\u00e9 \xe2\x82\xac \\ud83d\\ude00\"}"
+
+		Convey("It should decode it like UnmarshalJSON", func() {
+			var expected, actual Message
+			json.Unmarshal([]byte(encoded), &expected)
+
+			So(actual.DecodeJSON(iotest.OneByteReader(strings.NewReader(encoded))), ShouldBeNil)
+			So(actual, ShouldResemble, expected)
+		})
+	})
+
+	Convey("Given a validated Message", t, func() {
+		message := Message{Type: TypeNotification}
+		validation.SetValidated(&message, true)
+
+		Convey("It should no longer be validated once decoded again", func() {
+			message.DecodeJSON(strings.NewReader(`{"Type": "Notification"}`))
+
+			So(message.IsValidated(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestMarshalJSONMethod(t *testing.T) {
+	Convey("Given a Message with an empty but present subject", t, func() {
+		message := Message{Type: "Notification", Message: "Test notification"}
+		message.SetField(KeySubject, "")
+
+		Convey("It should encode the present keys only", func() {
+			encoded, err := json.Marshal(message)
+
+			So(err, ShouldBeNil)
+			So(string(encoded), ShouldEqual, `{"Type":"Notification","Message":"Test notification","Subject":""}`)
+		})
+
+		Convey("It should be decoded with the same keys present", func() {
+			encoded, _ := json.Marshal(message)
+
+			var decoded Message
+			json.Unmarshal(encoded, &decoded)
+
+			for _, key := range Keys {
+				value, present := message.Field(key)
+				decodedValue, decodedPresent := decoded.Field(key)
+
+				So(decodedValue, ShouldEqual, value)
+				So(decodedPresent, ShouldEqual, present)
+			}
+		})
+	})
+}
+
+func TestIsValidatedMethod(t *testing.T) {
+	Convey("Given a Message", t, func() {
+		message := Message{}
+
+		Convey("It should not be validated", func() {
+			So(message.IsValidated(), ShouldBeFalse)
+		})
+
+		Convey("It should be validated once marked by the module", func() {
+			validation.SetValidated(&message, true)
+
+			So(message.IsValidated(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a validated Message", t, func() {
+		message := Message{Type: TypeNotification, Message: "Test notification"}
+		validation.SetValidated(&message, true)
+
+		Convey("It should stay validated when copied unmodified", func() {
+			copied := message
+
+			So(copied.IsValidated(), ShouldBeTrue)
+		})
+
+		Convey("It should not be validated once a field is modified", func() {
+			copied := message
+			copied.Message = "Forged notification"
+
+			So(copied.IsValidated(), ShouldBeFalse)
+			So(message.IsValidated(), ShouldBeTrue)
+		})
+
+		Convey("It should not be validated once a field is set, even to the same value", func() {
+			message.SetField(KeyMessage, message.Message)
+
+			So(message.IsValidated(), ShouldBeFalse)
+		})
+
+		Convey("It should not be validated once a key is marked present", func() {
+			message.present |= fieldSubject
+
+			So(message.IsValidated(), ShouldBeFalse)
+		})
+
+		Convey("It should not be validated once JSON is decoded into it", func() {
+			So(json.Unmarshal([]byte(`{"Subject":"Test subject"}`), &message), ShouldBeNil)
+
+			So(message.IsValidated(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestIsFIFOMethod(t *testing.T) {
+	Convey("Given a Message from a FIFO topic", t, func() {
+		message := Message{TopicArn: "arn:aws:sns:us-west-2:123456789012:MyTopic.fifo"}
+
+		Convey("It should be FIFO", func() {
+			So(message.IsFIFO(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a Message from a standard topic", t, func() {
+		message := Message{TopicArn: "arn:aws:sns:us-west-2:123456789012:MyTopic"}
+
+		Convey("It should not be FIFO", func() {
+			So(message.IsFIFO(), ShouldBeFalse)
+		})
+	})
+}
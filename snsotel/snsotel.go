@@ -0,0 +1,60 @@
+// Package snsotel adapts an OpenTelemetry tracer to snsvalidator.Tracer, so
+// that the validation steps are traced as OpenTelemetry spans. The core
+// packages do not depend on OpenTelemetry, only this package does.
+package snsotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// AttrErrorType is the key of the span attribute of the type of the error
+// failing the step, see snserrors.Outcome
+const AttrErrorType = "sns.error_type"
+
+// Tracer is a snsvalidator.Tracer starting the spans with an OpenTelemetry
+// tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ snsvalidator.Tracer = (*Tracer)(nil)
+
+// New returns a new Tracer starting the spans with the OpenTelemetry tracer,
+// e.g. otel.Tracer("github.com/yuhlau/go-sns-message-validator")
+func New(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// Start starts an OpenTelemetry span of the name as a child of the span in
+// the context
+func (tracer *Tracer) Start(ctx context.Context, name string) (context.Context, snsvalidator.Span) {
+	ctx, span := tracer.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, otelSpan{span: span}
+}
+
+// otelSpan is a snsvalidator.Span of an OpenTelemetry span
+type otelSpan struct {
+	span trace.Span
+}
+
+func (span otelSpan) SetAttribute(key string, value string) {
+	span.span.SetAttributes(attribute.String(key, value))
+}
+
+// End records the error on the span and sets its status to error, if the step
+// failed, and ends the span
+func (span otelSpan) End(err error) {
+	if err != nil {
+		span.span.SetAttributes(attribute.String(AttrErrorType, snserrors.Outcome(err)))
+		span.span.RecordError(err)
+		span.span.SetStatus(codes.Error, err.Error())
+	}
+	span.span.End()
+}
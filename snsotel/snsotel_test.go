@@ -0,0 +1,101 @@
+package snsotel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Notification signed by _assets/fakecert.key
+var notificationMessage = snsmessage.SNSMessage{
+	Type:             "Notification",
+	MessageId:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+	TopicArn:         "arn:aws:sns:us-west-2:123456789012:MyTopic",
+	Message:          "Test notification",
+	Subject:          "Test subject",
+	Timestamp:        "2012-04-26T20:45:04.751Z",
+	SignatureVersion: "1",
+	Signature:        "ol5x/KiU+7dWKRuyD6Y1EntwXo+orXlVgQbq4JDy5uh/+EBBz/mfWQ0X0LXyyxkXXCykDakEz1F0h9y9xV9UitLlYA/tEMzI7WU9ob9d9L8YTCZVaHZUtCu4S0p0eCFzT69q+ijPuH9N1znuZOzDogsJIf8E9/8owtRmi6M50Co=",
+	SigningCertURL:   "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+	UnsubscribeURL:   "https://localhost/unsubscribe",
+}
+
+// spanNamed returns the ended span of the name
+func spanNamed(recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+// attributeOf returns the value of the attribute of the span
+func attributeOf(span sdktrace.ReadOnlySpan, key string) string {
+	for _, attr := range span.Attributes() {
+		if attr.Key == attribute.Key(key) {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestTracer(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier traced with OpenTelemetry", t, func() {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		verifier := snsvalidator.NewVerifier(
+			snsvalidator.WithCertFetcher(snsvalidator.DirCertFetcher(dir)),
+			snsvalidator.WithTracer(New(provider.Tracer("snsotel_test"))),
+		)
+
+		ctx, parent := provider.Tracer("snsotel_test").Start(context.Background(), "webhook")
+
+		Convey("When a valid message is verified", func() {
+			message := notificationMessage
+			So(message.VerifyWith(ctx, verifier), ShouldBeNil)
+			parent.End()
+
+			Convey("It should record the spans of the validation steps under the current span", func() {
+				validate := spanNamed(recorder, snsvalidator.SpanValidate)
+
+				So(validate, ShouldNotBeNil)
+				So(validate.Parent().SpanID(), ShouldEqual, parent.SpanContext().SpanID())
+				So(attributeOf(validate, snsvalidator.AttrMessageId), ShouldEqual, message.MessageId)
+				So(validate.Status().Code, ShouldEqual, codes.Unset)
+
+				resolve := spanNamed(recorder, snsvalidator.SpanResolveCertificate)
+				So(attributeOf(resolve, snsvalidator.AttrCertCache), ShouldEqual, snsvalidator.CertCacheMiss)
+				So(spanNamed(recorder, snsvalidator.SpanFetchCertificate), ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a forged message is verified", func() {
+			message := notificationMessage
+			message.Message = "Forged notification"
+			message.VerifyWith(ctx, verifier)
+
+			Convey("It should record the error on the failed spans", func() {
+				verify := spanNamed(recorder, snsvalidator.SpanVerifySignature)
+
+				So(verify.Status().Code, ShouldEqual, codes.Error)
+				So(attributeOf(verify, AttrErrorType), ShouldEqual, snsvalidator.ErrTypeIncorrectSignature)
+				So(len(verify.Events()), ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,124 @@
+// Package snssigner signs SNS messages exactly as SNS does, e.g. to
+// re-broadcast SNS-shaped messages which can be validated by the snsvalidator
+// package.
+package snssigner
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Types of SNSError returned by the package
+const (
+	ErrTypeInvalidKey                  = "InvalidKey"
+	ErrTypeUnsupportedSignatureVersion = "UnsupportedSignatureVersion"
+	ErrTypeSigningFailed               = "SigningFailed"
+)
+
+// Sentinel errors of the types of SNSError returned by the package, to be
+// used with errors.Is
+var (
+	ErrInvalidKey                  = snserrors.New(ErrTypeInvalidKey, "Invalid RSA private key")
+	ErrUnsupportedSignatureVersion = snserrors.New(ErrTypeUnsupportedSignatureVersion, "Unsupported signature version")
+	ErrSigningFailed               = snserrors.New(ErrTypeSigningFailed, "Could not sign the SNS message")
+)
+
+// Hash functions of the signature versions
+var signatureHashes = map[string]crypto.Hash{
+	snsvalidator.SignatureVersion1: crypto.SHA1,
+	snsvalidator.SignatureVersion2: crypto.SHA256,
+}
+
+// Signer signs SNS messages with a RSA private key. The certificate of the key
+// must be served at CertURL for the messages to be validated.
+type Signer struct {
+	// Key is the RSA private key, e.g. *rsa.PrivateKey
+	Key crypto.Signer
+	// CertURL is the URL of the certificate of Key
+	CertURL string
+	// SignatureVersion of the signatures. If it is empty,
+	// snsvalidator.SignatureVersion1 is used
+	SignatureVersion string
+}
+
+// New returns a new Signer signing with the RSA private key of version 1
+// signature
+func New(key *rsa.PrivateKey, certURL string) *Signer {
+	return &Signer{
+		Key:              key,
+		CertURL:          certURL,
+		SignatureVersion: snsvalidator.SignatureVersion1,
+	}
+}
+
+// NewFromPEM returns a new Signer like New with the PEM-encoded RSA private
+// key, in either PKCS #1 or PKCS #8 form.
+// If the key cannot be decoded or is not a RSA key, it returns SNSError of
+// type ErrInvalidKey
+func NewFromPEM(keyPEM []byte, certURL string) (*Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, snserrors.New(ErrTypeInvalidKey, "Could not decode the private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return New(key, certURL), nil
+	}
+
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, snserrors.Wrap(ErrTypeInvalidKey, err, "")
+	}
+	key, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, snserrors.New(ErrTypeInvalidKey, "The private key is not a RSA key")
+	}
+	return New(key, certURL), nil
+}
+
+// Sign signs the SNSMessage. It computes the signable string of the message
+// like the validator does, and sets the "Signature", "SignatureVersion" and
+// "SigningCertURL" of the SNSMessage. The SNSMessage should not be modified
+// after signing.
+// If the signature version is unsupported, it returns SNSError of type
+// ErrUnsupportedSignatureVersion
+// If the key fails to sign, it returns SNSError of type ErrSigningFailed
+// The SNSMessage is left unchanged on failure.
+func (signer *Signer) Sign(message *snsmessage.SNSMessage) error {
+	signatureVersion := signer.SignatureVersion
+	if signatureVersion == "" {
+		signatureVersion = snsvalidator.SignatureVersion1
+	}
+
+	hash, supported := signatureHashes[signatureVersion]
+	if !supported {
+		return snserrors.New(
+			ErrTypeUnsupportedSignatureVersion,
+			fmt.Sprintf("Unsupported signature version \"%s\"", signatureVersion),
+		)
+	}
+
+	digest := hash.New()
+	digest.Write(message.GetValidator().SignableString())
+
+	signature, err := signer.Key.Sign(rand.Reader, digest.Sum(nil), hash)
+	if err != nil {
+		return snserrors.Wrap(ErrTypeSigningFailed, err, "")
+	}
+
+	message.Signature = base64.StdEncoding.EncodeToString(signature)
+	message.SignatureVersion = signatureVersion
+	message.SigningCertURL = signer.CertURL
+	return nil
+}
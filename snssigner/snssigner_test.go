@@ -0,0 +1,118 @@
+package snssigner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/h2non/gock.v1"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+const certURL = "https://sns.ap-northeast-1.amazonaws.com/cert.pem"
+
+func newNotificationMessage() *snsmessage.SNSMessage {
+	return &snsmessage.SNSMessage{
+		Type:           "Notification",
+		MessageId:      "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		TopicArn:       "arn:aws:sns:us-west-2:123456789012:MyTopic",
+		Message:        `{"id":1,"name":"test"}`,
+		Subject:        "Test subject",
+		Timestamp:      "2012-04-26T20:45:04.751Z",
+		UnsubscribeURL: "https://localhost/unsubscribe",
+	}
+}
+
+func newFakecertSigner() *Signer {
+	keyPEM, _ := ioutil.ReadFile("../_assets/fakecert.key")
+	signer, err := NewFromPEM(keyPEM, certURL)
+	So(err, ShouldBeNil)
+	return signer
+}
+
+func TestSignMethod(t *testing.T) {
+	Convey("Given a Signer of _assets/fakecert.key", t, func() {
+		signer := newFakecertSigner()
+		message := newNotificationMessage()
+
+		Convey("It should sign like _tools/sign_sha1withrsa", func() {
+			err := signer.Sign(message)
+
+			So(err, ShouldBeNil)
+			So(message.Signature, ShouldEqual, "Z2ZxqGoxh1zOankzqfvCMZlSHaWriMB8SlH36camvWEBpLvha2P5Y3nm1pCWW+OvomleeFeME6LMsCaysV5R8eESfmLvxQ5U5ETNVOSheEVfzVWxUTV6nSrgiq0OomOMyKGE2FbFGyhuARAvZSMKGLjvQraRqJ/Pb/y6wIYeLbU=")
+			So(message.SignatureVersion, ShouldEqual, snsvalidator.SignatureVersion1)
+			So(message.SigningCertURL, ShouldEqual, certURL)
+		})
+
+		Convey("When signing with signature version 2", func() {
+			gock.New("https://sns.ap-northeast-1.amazonaws.com").
+				Get("cert.pem").
+				Reply(200).
+				File("../_assets/fakecert.pem")
+
+			signer.SignatureVersion = snsvalidator.SignatureVersion2
+			err := signer.Sign(message)
+
+			So(err, ShouldBeNil)
+			So(message.SignatureVersion, ShouldEqual, snsvalidator.SignatureVersion2)
+
+			Convey("It should pass the validation", func() {
+				So(message.Validate(), ShouldBeNil)
+			})
+		})
+
+		Convey("When signing with unsupported signature version", func() {
+			signer.SignatureVersion = "3"
+			err := signer.Sign(message)
+
+			Convey("It should return a SNSError and leave the message unchanged", func() {
+				So(errors.Is(err, ErrUnsupportedSignatureVersion), ShouldBeTrue)
+				So(message, ShouldResemble, newNotificationMessage())
+			})
+		})
+	})
+}
+
+func TestNewFromPEM(t *testing.T) {
+	Convey("Given a PKCS #8 RSA private key", t, func() {
+		key, _ := rsa.GenerateKey(rand.Reader, 1024)
+		der, _ := x509.MarshalPKCS8PrivateKey(key)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+		Convey("It should return a Signer of the key", func() {
+			signer, err := NewFromPEM(keyPEM, certURL)
+
+			So(err, ShouldBeNil)
+			So(signer.Key.Public(), ShouldResemble, key.Public())
+		})
+	})
+
+	Convey("Given a PKCS #8 non-RSA private key", t, func() {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, _ := x509.MarshalPKCS8PrivateKey(key)
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+		Convey("It should return a SNSError of type ErrInvalidKey", func() {
+			_, err := NewFromPEM(keyPEM, certURL)
+
+			So(errors.Is(err, ErrInvalidKey), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a non-PEM private key", t, func() {
+		Convey("It should return a SNSError of type ErrInvalidKey", func() {
+			_, err := NewFromPEM([]byte("invalid key"), certURL)
+
+			So(errors.Is(err, ErrInvalidKey), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,128 @@
+// Package snstest provides utilities for testing SNS message handlers.
+//
+// A Server generates an ephemeral RSA key and a self-signed certificate, and
+// serves the certificate from a local TLS server. It signs SNS messages with
+// the key exactly as SNS does, so that the messages pass the validation of a
+// validator configured to trust the local server, without reaching AWS.
+package snstest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snssigner"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// CertPath is the path of the signing certificate on the Server
+const CertPath = "/cert.pem"
+
+// Server is a local TLS server serving the signing certificate of the SNS
+// messages it signs with a snssigner.Signer
+type Server struct {
+	*httptest.Server
+
+	// CertURL is the URL of the signing certificate
+	CertURL string
+
+	key     *rsa.PrivateKey
+	certPEM []byte
+	mux     *http.ServeMux
+}
+
+// NewServer starts and returns a new Server with an ephemeral key and
+// certificate. The caller should call Close when finished, to shut it down.
+// It panics if the key or the certificate cannot be generated, like
+// httptest.NewServer panics if it cannot listen.
+func NewServer() *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("snstest: failed to generate key: %v", err))
+	}
+
+	certPEM, err := newCertificate(key)
+	if err != nil {
+		panic(fmt.Sprintf("snstest: failed to create certificate: %v", err))
+	}
+
+	server := &Server{key: key, certPEM: certPEM, mux: http.NewServeMux()}
+
+	server.mux.HandleFunc(CertPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(server.certPEM)
+	})
+	server.Server = httptest.NewTLSServer(server.mux)
+	server.CertURL = server.URL + CertPath
+
+	return server
+}
+
+// newCertificate returns a PEM-encoded self-signed certificate of the key
+func newCertificate(key *rsa.PrivateKey) ([]byte, error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// Handle registers the handler for the given pattern on the Server, in
+// addition to the signing certificate at CertPath
+func (server *Server) Handle(pattern string, handler http.Handler) {
+	server.mux.Handle(pattern, handler)
+}
+
+// CertificatePEM returns the PEM-encoded signing certificate
+func (server *Server) CertificatePEM() []byte {
+	return server.certPEM
+}
+
+// Signer returns the snssigner.Signer of the Server with the given signature
+// version
+func (server *Server) Signer(signatureVersion string) *snssigner.Signer {
+	signer := snssigner.New(server.key, server.CertURL)
+	signer.SignatureVersion = signatureVersion
+	return signer
+}
+
+// Sign signs the SNSMessage with the given signature version. It sets the
+// "SignatureVersion", "SigningCertURL" and "Signature" of the SNSMessage. The
+// SNSMessage should not be modified after signing.
+// It returns an error if the signature version is unsupported.
+func (server *Server) Sign(message *snsmessage.SNSMessage, signatureVersion string) error {
+	return server.Signer(signatureVersion).Sign(message)
+}
+
+// HostPattern returns the pattern matching the hostname of the Server only
+func (server *Server) HostPattern() *regexp.Regexp {
+	parsedUrl, _ := url.Parse(server.URL)
+	return regexp.MustCompile("^" + regexp.QuoteMeta(parsedUrl.Hostname()) + "$")
+}
+
+// Validator returns the SNSValidator of the SNSMessage configured to trust
+// the Server and retrieve the certificate with a client trusting its TLS
+// certificate
+func (server *Server) Validator(message *snsmessage.SNSMessage) *snsvalidator.SNSValidator {
+	validator := message.GetValidator()
+	validator.HTTPClient = server.Client()
+	validator.HostPattern = server.HostPattern()
+	return validator
+}
@@ -0,0 +1,118 @@
+package snstest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+func newNotificationMessage() *snsmessage.SNSMessage {
+	return &snsmessage.SNSMessage{
+		Type:      "Notification",
+		MessageId: "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		TopicArn:  "arn:aws:sns:us-west-2:123456789012:MyTopic",
+		Message:   `{"id":1,"name":"test"}`,
+		Subject:   "Test subject",
+		Timestamp: "2012-04-26T20:45:04.751Z",
+	}
+}
+
+func TestSignMethod(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	for _, signatureVersion := range []string{snsvalidator.SignatureVersion1, snsvalidator.SignatureVersion2} {
+		Convey(fmt.Sprintf("Given a SNSMessage signed with signature version %s", signatureVersion), t, func() {
+			message := newNotificationMessage()
+			err := server.Sign(message, signatureVersion)
+
+			So(err, ShouldBeNil)
+			So(message.SignatureVersion, ShouldEqual, signatureVersion)
+			So(message.SigningCertURL, ShouldEqual, server.CertURL)
+
+			Convey("It should pass the validation of the Server validator", func() {
+				So(server.Validator(message).ValidateMessage(), ShouldBeNil)
+			})
+
+			Convey("It should fail the validation of the default validator", func() {
+				err := message.GetValidator().ValidateMessage()
+
+				So(errors.Is(err, snsvalidator.ErrInvalidCert), ShouldBeTrue)
+			})
+
+			Convey("It should fail the validation once modified", func() {
+				message.Message = "Forged notification"
+				err := server.Validator(message).ValidateMessage()
+
+				So(errors.Is(err, snsvalidator.ErrIncorrectSignature), ShouldBeTrue)
+			})
+		})
+	}
+
+	Convey("Given a SNSMessage signed with the certificate of another Server", t, func() {
+		other := NewServer()
+		defer other.Close()
+
+		message := newNotificationMessage()
+		other.Sign(message, snsvalidator.SignatureVersion1)
+		message.SigningCertURL = server.CertURL
+
+		Convey("It should not match the certificate of the Server", func() {
+			err := server.Validator(message).ValidateMessage()
+
+			So(errors.Is(err, snsvalidator.ErrIncorrectSignature), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an unsupported signature version", t, func() {
+		message := newNotificationMessage()
+
+		Convey("It should return an error and leave the SNSMessage unsigned", func() {
+			err := server.Sign(message, "3")
+
+			So(err, ShouldNotBeNil)
+			So(message.Signature, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestCertificatePEMMethod(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	Convey("Given a Server", t, func() {
+		Convey("It should serve its certificate at CertURL", func() {
+			res, err := server.Client().Get(server.CertURL)
+			So(err, ShouldBeNil)
+			defer res.Body.Close()
+
+			body := make([]byte, len(server.CertificatePEM())+1)
+			n, _ := res.Body.Read(body)
+			So(string(body[:n]), ShouldEqual, string(server.CertificatePEM()))
+		})
+	})
+}
+
+func ExampleServer() {
+	server := NewServer()
+	defer server.Close()
+
+	message := &snsmessage.SNSMessage{
+		Type:      "Notification",
+		MessageId: "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		TopicArn:  "arn:aws:sns:us-west-2:123456789012:MyTopic",
+		Message:   "Test notification",
+		Timestamp: "2012-04-26T20:45:04.751Z",
+	}
+	if err := server.Sign(message, snsvalidator.SignatureVersion2); err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Println(server.Validator(message).ValidateMessage())
+	// Output: <nil>
+}
@@ -0,0 +1,127 @@
+package snsvalidator
+
+import (
+	"context"
+	"crypto/x509"
+	"regexp"
+	"sync"
+)
+
+// DefaultBatchWorkers is the default number of messages a Verifier validates
+// concurrently in ValidateBatch. It is the maximum number of messages SQS
+// returns per receive.
+const DefaultBatchWorkers = 10
+
+// WithBatchWorkers sets the number of messages validated concurrently in
+// ValidateBatch. A non-positive number means DefaultBatchWorkers
+func WithBatchWorkers(workers int) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.batchWorkers = workers
+	}
+}
+
+// ValidateBatch validates the messages concurrently like Verify does, with at
+// most the number of workers set by WithBatchWorkers. Each signing
+// certificate is retrieved once for the whole batch, even if the certificate
+// cache is disabled.
+// It returns the errors of the messages in the order of the messages, with
+// nil for the valid messages.
+func (verifier *Verifier) ValidateBatch(ctx context.Context, messages []Message) []error {
+	errs := make([]error, len(messages))
+	fetcher := &batchCertFetcher{
+		fetcher: verifier.certFetcher,
+		calls:   make(map[string]*certCall),
+	}
+
+	workers := verifier.batchWorkers
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	if workers > len(messages) {
+		workers = len(messages)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				validator := verifier.Validator(messages[index])
+				validator.CertFetcher = fetcher
+				errs[index] = verifier.validate(ctx, validator)
+			}
+		}()
+	}
+
+	for index := range messages {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
+
+// certCall is a retrieval of a signing certificate shared by a batch, or by
+// the concurrent misses of a cachingCertFetcher
+type certCall struct {
+	done     chan struct{}
+	certData []byte
+	err      error
+}
+
+// batchCertFetcher retrieves each signing certificate once with the
+// underlying CertFetcher and shares the result, including failure, with the
+// other messages of the batch
+type batchCertFetcher struct {
+	fetcher CertFetcher
+
+	mu    sync.Mutex
+	calls map[string]*certCall
+}
+
+func (batch *batchCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	batch.mu.Lock()
+	call, exists := batch.calls[certURL]
+	if !exists {
+		call = &certCall{done: make(chan struct{})}
+		batch.calls[certURL] = call
+	}
+	batch.mu.Unlock()
+
+	if exists {
+		select {
+		case <-call.done:
+			return call.certData, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	call.certData, call.err = batch.fetcher.FetchCertificate(ctx, certURL)
+	close(call.done)
+	return call.certData, call.err
+}
+
+// parseCertificate parses the certificate with the underlying CertFetcher, so
+// that the certificate cache of the Verifier is used
+func (batch *batchCertFetcher) parseCertificate(certURL string, certData []byte) (*x509.Certificate, error) {
+	if parser, ok := batch.fetcher.(certParser); ok {
+		return parser.parseCertificate(certURL, certData)
+	}
+	return parseCertificate(certData)
+}
+
+// trustsCertURL checks the certificate URL with the underlying CertFetcher,
+// so that the certificate cache of the Verifier is used
+func (batch *batchCertFetcher) trustsCertURL(certURL string, hostPattern *regexp.Regexp) bool {
+	truster, ok := batch.fetcher.(certURLTruster)
+	return ok && truster.trustsCertURL(certURL, hostPattern)
+}
+
+func (batch *batchCertFetcher) trustCertURL(certURL string, hostPattern *regexp.Regexp) {
+	if truster, ok := batch.fetcher.(certURLTruster); ok {
+		truster.trustCertURL(certURL, hostPattern)
+	}
+}
@@ -0,0 +1,103 @@
+package snsvalidator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// blockingCertFetcher records the maximum number of concurrent retrievals of
+// the certificates, each taking a while to complete
+type blockingCertFetcher struct {
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (fetcher *blockingCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	fetcher.mu.Lock()
+	fetcher.current++
+	if fetcher.current > fetcher.max {
+		fetcher.max = fetcher.current
+	}
+	fetcher.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	fetcher.mu.Lock()
+	fetcher.current--
+	fetcher.mu.Unlock()
+	return nil, errors.New("Certificate not found")
+}
+
+func TestValidateBatchMethod(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier without certificate cache and a batch of messages", t, func() {
+		fetcher := &countingCertFetcher{CertFetcher: DirCertFetcher(dir)}
+		verifier := NewVerifier(WithCertFetcher(fetcher), WithCertCache(0))
+
+		invalid := newValidSignatureMessage()
+		invalid["Message"] = "Forged notification"
+		messages := []Message{
+			newValidSignatureMessage(),
+			invalid,
+			mapMessage{"Type": "Notification"},
+			newValidSignatureMessage(),
+		}
+
+		Convey("It should return the errors in the order of the messages", func() {
+			errs := verifier.ValidateBatch(context.Background(), messages)
+
+			So(len(errs), ShouldEqual, len(messages))
+			So(errs[0], ShouldBeNil)
+			So(errors.Is(errs[1], ErrIncorrectSignature), ShouldBeTrue)
+			So(errors.Is(errs[2], ErrMissingKey), ShouldBeTrue)
+			So(errs[3], ShouldBeNil)
+		})
+
+		Convey("It should retrieve the certificate once for the batch", func() {
+			verifier.ValidateBatch(context.Background(), messages)
+
+			So(fetcher.count, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a Verifier with a worker limit and a batch of messages of different certificates", t, func() {
+		fetcher := &blockingCertFetcher{}
+		verifier := NewVerifier(WithCertFetcher(fetcher), WithBatchWorkers(2))
+
+		var messages []Message
+		for i := 0; i < 6; i++ {
+			message := newValidSignatureMessage()
+			message["SigningCertURL"] = fmt.Sprintf("https://sns.ap-northeast-1.amazonaws.com/cert-%d.pem", i)
+			messages = append(messages, message)
+		}
+
+		Convey("It should validate at most the number of workers at a time", func() {
+			errs := verifier.ValidateBatch(context.Background(), messages)
+
+			So(fetcher.max, ShouldEqual, 2)
+			for _, err := range errs {
+				So(errors.Is(err, ErrInvalidCert), ShouldBeTrue)
+			}
+		})
+	})
+
+	Convey("Given an empty batch", t, func() {
+		verifier := NewVerifier()
+
+		Convey("It should return no errors", func() {
+			So(verifier.ValidateBatch(context.Background(), nil), ShouldBeEmpty)
+		})
+	})
+}
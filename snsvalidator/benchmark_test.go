@@ -0,0 +1,99 @@
+package snsvalidator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmodel"
+)
+
+// newBenchmarkMessage returns the snsmodel.Message of
+// newValidSignatureMessage
+func newBenchmarkMessage() *snsmodel.Message {
+	message := &snsmodel.Message{}
+	for key, value := range newValidSignatureMessage() {
+		message.SetField(key, value)
+	}
+	return message
+}
+
+// newBenchmarkCertDir returns the directory of the signing certificate of
+// newBenchmarkMessage
+func newBenchmarkCertDir(b *testing.B) DirCertFetcher {
+	dir := b.TempDir()
+	certData, err := os.ReadFile("../_assets/fakecert.pem")
+	if err != nil {
+		b.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+	return DirCertFetcher(dir)
+}
+
+func BenchmarkVerifierVerify(b *testing.B) {
+	verifier := NewVerifier(WithCertFetcher(newBenchmarkCertDir(b)))
+	message := newBenchmarkMessage()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := verifier.Verify(ctx, message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifierVerifyCachedVerification(b *testing.B) {
+	verifier := NewVerifier(WithCertFetcher(newBenchmarkCertDir(b)), WithVerificationCache(100))
+	message := newBenchmarkMessage()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := verifier.Verify(ctx, message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifierVerifyUncached(b *testing.B) {
+	verifier := NewVerifier(WithCertFetcher(newBenchmarkCertDir(b)), WithCertCache(0))
+	message := newBenchmarkMessage()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := verifier.Verify(ctx, message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidateMessage(b *testing.B) {
+	fetcher := newBenchmarkCertDir(b)
+	message := newBenchmarkMessage()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		validator := New(message)
+		validator.CertFetcher = fetcher
+		if err := validator.ValidateMessage(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSignableString(b *testing.B) {
+	validator := New(newBenchmarkMessage())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		validator.SignableString()
+	}
+}
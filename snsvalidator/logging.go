@@ -0,0 +1,46 @@
+package snsvalidator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+)
+
+// WithLogger sets the logger of the validations, see SNSValidator.Logger
+func WithLogger(logger *slog.Logger) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.logger = logger
+	}
+}
+
+// log emits a record with the "MessageId" and "TopicArn" of the underlying
+// SNS message and the attributes, if the Logger is set and enabled for the
+// level. The "Message" and "Signature" are never logged.
+func (validator *SNSValidator) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if validator.Logger == nil || !validator.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs = append([]slog.Attr{
+		slog.String("message_id", validator.value("MessageId")),
+		slog.String("topic_arn", validator.value("TopicArn")),
+	}, attrs...)
+	validator.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// errorAttrs returns the attributes of the error: its type, code, message and
+// whether it is temporary
+func errorAttrs(err error) []slog.Attr {
+	attrs := []slog.Attr{slog.String("error_type", snserrors.Outcome(err))}
+
+	var snsErr *snserrors.SNSError
+	if errors.As(err, &snsErr) {
+		attrs = append(attrs, slog.String("error_code", snsErr.Code()))
+	}
+	return append(attrs,
+		slog.String("error", err.Error()),
+		slog.Bool("temporary", snserrors.IsTemporary(err)),
+	)
+}
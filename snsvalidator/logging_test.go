@@ -0,0 +1,105 @@
+package snsvalidator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// decodeRecords decodes the JSON records logged by slog.JSONHandler
+func decodeRecords(buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := map[string]interface{}{}
+		json.Unmarshal([]byte(line), &record)
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier with a debug Logger", t, func() {
+		buf := &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithLogger(logger))
+		message := newValidSignatureMessage()
+
+		Convey("When a valid message is verified", func() {
+			So(verifier.Verify(context.Background(), message), ShouldBeNil)
+			records := decodeRecords(buf)
+
+			Convey("It should log the retrieval and the verification at debug level", func() {
+				So(len(records), ShouldEqual, 2)
+				So(records[0]["msg"], ShouldEqual, "Retrieved the signing certificate")
+				So(records[0]["cert_url"], ShouldEqual, message["SigningCertURL"])
+				So(records[0], ShouldContainKey, "duration")
+				So(records[1]["msg"], ShouldEqual, "Verified the signature")
+				So(records[1]["level"], ShouldEqual, "DEBUG")
+				So(records[1]["message_id"], ShouldEqual, message["MessageId"])
+				So(records[1]["topic_arn"], ShouldEqual, message["TopicArn"])
+			})
+		})
+
+		Convey("When a forged message is verified", func() {
+			message["Message"] = "Forged notification"
+			verifier.Verify(context.Background(), message)
+			records := decodeRecords(buf)
+
+			Convey("It should log the failure at warn level", func() {
+				failure := records[len(records)-1]
+
+				So(failure["level"], ShouldEqual, "WARN")
+				So(failure["msg"], ShouldEqual, "Failed to verify the signature")
+				So(failure["step"], ShouldEqual, StepCheckSignature)
+				So(failure["error_type"], ShouldEqual, ErrTypeIncorrectSignature)
+				So(failure["error_code"], ShouldEqual, CodeSignatureMismatch)
+				So(failure["temporary"], ShouldEqual, false)
+			})
+
+			Convey("It should not log the message body or the signature", func() {
+				So(buf.String(), ShouldNotContainSubstring, "Forged notification")
+				So(buf.String(), ShouldNotContainSubstring, message["Signature"])
+			})
+		})
+
+		Convey("When the certificate is not found", func() {
+			message["SigningCertURL"] = "https://sns.ap-northeast-1.amazonaws.com/absent.pem"
+			verifier.Verify(context.Background(), message)
+			records := decodeRecords(buf)
+
+			Convey("It should log the failed retrieval once at warn level", func() {
+				So(len(records), ShouldEqual, 2)
+				So(records[0]["level"], ShouldEqual, "DEBUG")
+				So(records[0]["error_type"], ShouldEqual, ErrTypeInvalidCert)
+				So(records[1]["level"], ShouldEqual, "WARN")
+				So(records[1]["step"], ShouldEqual, StepFetchCert)
+			})
+		})
+	})
+
+	Convey("Given a SNSValidator with a Logger at warn level", t, func() {
+		buf := &bytes.Buffer{}
+		validator := newValidSignatureMessageValidator()
+		validator.CertFetcher = DirCertFetcher(dir)
+		validator.Logger = slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+		Convey("It should not log a valid message", func() {
+			So(validator.ValidateMessage(), ShouldBeNil)
+			So(buf.Len(), ShouldEqual, 0)
+		})
+	})
+}
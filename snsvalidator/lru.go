@@ -0,0 +1,66 @@
+package snsvalidator
+
+import "container/list"
+
+// lruCache is a bounded map evicting the least recently used entry. It is not
+// safe for concurrent use, the caches embedding it hold their own lock
+type lruCache[K comparable, V any] struct {
+	size    int
+	entries map[K]*list.Element
+	// order lists the entries from the most recently used
+	order *list.List
+}
+
+// lruEntry is an entry of a lruCache
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRUCache[K comparable, V any](size int) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		size:    size,
+		entries: make(map[K]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the value of the key, and marks it as the most recently used if
+// it exists
+func (cache *lruCache[K, V]) get(key K) (V, bool) {
+	element, exists := cache.entries[key]
+	if !exists {
+		var zero V
+		return zero, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+// add sets the value of the key as the most recently used, evicting the least
+// recently used entry if the cache is full
+func (cache *lruCache[K, V]) add(key K, value V) {
+	if element, exists := cache.entries[key]; exists {
+		element.Value.(*lruEntry[K, V]).value = value
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	if cache.order.Len() >= cache.size {
+		cache.remove(cache.order.Back().Value.(*lruEntry[K, V]).key)
+	}
+	cache.entries[key] = cache.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+}
+
+// remove deletes the entry of the key if it exists
+func (cache *lruCache[K, V]) remove(key K) {
+	if element, exists := cache.entries[key]; exists {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+	}
+}
+
+// len returns the number of entries
+func (cache *lruCache[K, V]) len() int {
+	return cache.order.Len()
+}
@@ -0,0 +1,95 @@
+package snsvalidator
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+)
+
+// Observer observes the validations of a Verifier, e.g. to record metrics.
+// Its methods are called synchronously from the validating goroutines, so
+// they must be fast and safe for concurrent use. Embed NopObserver to observe
+// only some of the events.
+type Observer interface {
+	// ValidationStarted is called before a message is validated
+	ValidationStarted(ctx context.Context)
+	// ValidationFinished is called after a message is validated with the
+	// outcome of the validation, see snserrors.Outcome
+	ValidationFinished(ctx context.Context, outcome string, duration time.Duration)
+	// CertCacheLookup is called when a signing certificate is looked up in
+	// the certificate cache, with whether it is found
+	CertCacheLookup(ctx context.Context, certURL string, hit bool)
+	// CertFetched is called after a signing certificate is retrieved by the
+	// CertFetcher, with the HTTP status code of the retrieval and its error if
+	// it failed. The status code is the one of the response received by a
+	// StatusCertFetcher, such as HTTPCertFetcher, or of the failure, see
+	// snserrors.Details. It is 0 if no HTTP response is received, e.g. the
+	// request could not be completed or the CertFetcher does not use HTTP
+	CertFetched(ctx context.Context, certURL string, statusCode int, duration time.Duration, err error)
+}
+
+// NopObserver is an Observer ignoring all events
+type NopObserver struct{}
+
+func (NopObserver) ValidationStarted(ctx context.Context) {}
+
+func (NopObserver) ValidationFinished(ctx context.Context, outcome string, duration time.Duration) {}
+
+func (NopObserver) CertCacheLookup(ctx context.Context, certURL string, hit bool) {}
+
+func (NopObserver) CertFetched(
+	ctx context.Context, certURL string, statusCode int, duration time.Duration, err error,
+) {
+}
+
+// WithObserver sets the Observer of the validations
+func WithObserver(observer Observer) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.observer = observer
+	}
+}
+
+// validate validates the message of the validator and reports the validation
+// to the Observer
+func (verifier *Verifier) validate(ctx context.Context, validator *SNSValidator) error {
+	verifier.observer.ValidationStarted(ctx)
+	start := time.Now()
+
+	err := validator.ValidateMessageContext(ctx)
+	verifier.observer.ValidationFinished(ctx, snserrors.Outcome(err), time.Since(start))
+	return err
+}
+
+// observingCertFetcher reports the retrievals of the signing certificates by
+// the underlying CertFetcher to the Observer
+type observingCertFetcher struct {
+	fetcher  CertFetcher
+	observer Observer
+}
+
+func (observing observingCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	start := time.Now()
+	var certData []byte
+	var statusCode int
+	var err error
+	if statusFetcher, ok := observing.fetcher.(StatusCertFetcher); ok {
+		certData, statusCode, err = statusFetcher.FetchCertificateStatus(ctx, certURL)
+	} else {
+		certData, err = observing.fetcher.FetchCertificate(ctx, certURL)
+		statusCode = fetchStatusCode(err)
+	}
+	observing.observer.CertFetched(ctx, certURL, statusCode, time.Since(start), err)
+	return certData, err
+}
+
+// fetchStatusCode returns the HTTP status code of the certificate retrieval
+// failing with the error, 0 if there is none, see Observer.CertFetched
+func fetchStatusCode(err error) int {
+	var snsErr *snserrors.SNSError
+	if errors.As(err, &snsErr) {
+		return snsErr.Details().StatusCode
+	}
+	return 0
+}
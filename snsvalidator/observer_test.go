@@ -0,0 +1,140 @@
+package snsvalidator
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/h2non/gock.v1"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+)
+
+// recordingObserver records the events observed
+type recordingObserver struct {
+	NopObserver
+
+	mu       sync.Mutex
+	started  int
+	outcomes []string
+	lookups  []bool
+	fetches  []error
+	statuses []int
+}
+
+func (observer *recordingObserver) ValidationStarted(ctx context.Context) {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	observer.started++
+}
+
+func (observer *recordingObserver) ValidationFinished(ctx context.Context, outcome string, duration time.Duration) {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	observer.outcomes = append(observer.outcomes, outcome)
+}
+
+func (observer *recordingObserver) CertCacheLookup(ctx context.Context, certURL string, hit bool) {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	observer.lookups = append(observer.lookups, hit)
+}
+
+func (observer *recordingObserver) CertFetched(
+	ctx context.Context, certURL string, statusCode int, duration time.Duration, err error,
+) {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	observer.fetches = append(observer.fetches, err)
+	observer.statuses = append(observer.statuses, statusCode)
+}
+
+func TestWithObserver(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier with an Observer", t, func() {
+		observer := &recordingObserver{}
+		verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithObserver(observer))
+
+		Convey("When messages are verified", func() {
+			verifier.Verify(context.Background(), newValidSignatureMessage())
+			verifier.Verify(context.Background(), newValidSignatureMessage())
+			verifier.Verify(context.Background(), mapMessage{"Type": "Notification"})
+
+			Convey("It should observe the start and the outcome of the validations", func() {
+				So(observer.started, ShouldEqual, 3)
+				So(observer.outcomes, ShouldResemble, []string{snserrors.OutcomeValid, snserrors.OutcomeValid, ErrTypeMissingKey})
+			})
+
+			Convey("It should observe the certificate cache lookups", func() {
+				So(observer.lookups, ShouldResemble, []bool{false, true})
+			})
+
+			Convey("It should observe the certificate retrievals", func() {
+				So(observer.fetches, ShouldResemble, []error{nil})
+				So(observer.statuses, ShouldResemble, []int{0})
+			})
+		})
+
+		Convey("When a certificate is not found", func() {
+			message := newValidSignatureMessage()
+			message["SigningCertURL"] = "https://sns.ap-northeast-1.amazonaws.com/absent.pem"
+			verifier.Verify(context.Background(), message)
+
+			Convey("It should observe the failed retrieval", func() {
+				So(len(observer.fetches), ShouldEqual, 1)
+				So(snserrors.Outcome(observer.fetches[0]), ShouldEqual, ErrTypeInvalidCert)
+				So(observer.outcomes, ShouldResemble, []string{ErrTypeInvalidCert})
+				So(observer.statuses, ShouldResemble, []int{0})
+			})
+		})
+	})
+
+	Convey("Given a Verifier with an Observer retrieving the certificates over HTTP", t, func() {
+		defer gock.Off()
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(http.StatusServiceUnavailable)
+		observer := &recordingObserver{}
+		verifier := NewVerifier(WithObserver(observer))
+
+		Convey("It should observe the status code of the failed retrieval", func() {
+			verifier.Verify(context.Background(), newValidSignatureMessage())
+
+			So(observer.statuses, ShouldResemble, []int{http.StatusServiceUnavailable})
+			So(snserrors.IsTemporary(observer.fetches[0]), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a Verifier with an Observer retrieving a certificate over HTTP", t, func() {
+		defer gock.Off()
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(http.StatusOK).
+			File("../_assets/fakecert.pem")
+		observer := &recordingObserver{}
+		verifier := NewVerifier(WithObserver(observer))
+
+		Convey("It should observe the status code of the response", func() {
+			So(verifier.Verify(context.Background(), newValidSignatureMessage()), ShouldBeNil)
+
+			So(observer.fetches, ShouldResemble, []error{nil})
+			So(observer.statuses, ShouldResemble, []int{http.StatusOK})
+		})
+	})
+
+	Convey("Given a Verifier with an Observer embedding NopObserver", t, func() {
+		verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithObserver(struct{ NopObserver }{}))
+
+		Convey("It should verify messages", func() {
+			So(verifier.Verify(context.Background(), newValidSignatureMessage()), ShouldBeNil)
+		})
+	})
+}
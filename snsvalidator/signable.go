@@ -0,0 +1,168 @@
+package snsvalidator
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"hash"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// errUndecodableCert is returned by parseCertificate if the certificate is not
+// PEM encoded
+var errUndecodableCert = errors.New("Could not decode the certificate")
+
+// signatureBuffers are the buffers of verifying a signature. They are pooled,
+// so that the signable string is streamed into the hash and the signature is
+// decoded without allocating once the pool is warm.
+type signatureBuffers struct {
+	hashes map[crypto.Hash]hash.Hash
+	// hash is the hash the signable string is written to
+	hash hash.Hash
+	// chunk copies the strings written to hash, which only takes []byte
+	chunk     [256]byte
+	digest    []byte
+	encoded   []byte
+	signature []byte
+}
+
+var signatureBuffersPool = sync.Pool{
+	New: func() interface{} {
+		return &signatureBuffers{hashes: make(map[crypto.Hash]hash.Hash, 2)}
+	},
+}
+
+// getSignatureBuffers returns signatureBuffers from the pool. Put them back
+// with putSignatureBuffers once the digest and the signature are no longer
+// used.
+func getSignatureBuffers() *signatureBuffers {
+	return signatureBuffersPool.Get().(*signatureBuffers)
+}
+
+func putSignatureBuffers(buffers *signatureBuffers) {
+	buffers.hash = nil
+	signatureBuffersPool.Put(buffers)
+}
+
+// WriteString writes the string to the hash chunk by chunk
+func (buffers *signatureBuffers) WriteString(s string) (int, error) {
+	for written := 0; written < len(s); {
+		n := copy(buffers.chunk[:], s[written:])
+		buffers.hash.Write(buffers.chunk[:n])
+		written += n
+	}
+	return len(s), nil
+}
+
+// digestSignableString returns the digest of the signable string of the
+// validator with the hash function. The digest is valid until the buffers are
+// put back to the pool.
+func (buffers *signatureBuffers) digestSignableString(validator *SNSValidator, hashFunc crypto.Hash) []byte {
+	digest, exists := buffers.hashes[hashFunc]
+	if !exists {
+		digest = hashFunc.New()
+		buffers.hashes[hashFunc] = digest
+	}
+	digest.Reset()
+
+	buffers.hash = digest
+	validator.writeSignableString(buffers)
+	buffers.digest = digest.Sum(buffers.digest[:0])
+	return buffers.digest
+}
+
+// decodeSignature base64 decodes the signature. The decoded signature is
+// valid until the buffers are put back to the pool.
+func (buffers *signatureBuffers) decodeSignature(signature string) ([]byte, error) {
+	buffers.encoded = append(buffers.encoded[:0], signature...)
+
+	size := base64.StdEncoding.DecodedLen(len(signature))
+	if cap(buffers.signature) < size {
+		buffers.signature = make([]byte, size)
+	}
+	n, err := base64.StdEncoding.Decode(buffers.signature[:size], buffers.encoded)
+	return buffers.signature[:n], err
+}
+
+// signableKeys returns the keys of the signable string of the underlying SNS
+// message
+func (validator *SNSValidator) signableKeys() []string {
+	if validator.isTypes(subscriptionMessageTypes) {
+		return signableKeysForSubscription
+	}
+	return signableKeysForNotification
+}
+
+// writeSignableString writes the signable string of the underlying SNS
+// message to the writer, key by key, without building it in memory.
+func (validator *SNSValidator) writeSignableString(w io.StringWriter) {
+	for _, key := range validator.signableKeys() {
+		// Some keys like "Subject" are included only if it is present, even
+		// if it is empty
+		if value, present := validator.field(key); present {
+			w.WriteString(key)
+			w.WriteString("\n")
+			w.WriteString(value)
+			w.WriteString("\n")
+		}
+	}
+}
+
+// buildSignableString returns signable string of the underlying SNS message.
+// The signable string is essential to verify the signature. The signature is
+// verified with writeSignableString instead, this is only for the callers
+// needing the signable string itself.
+func (validator *SNSValidator) buildSignableString() []byte {
+	size := 0
+	for _, key := range validator.signableKeys() {
+		if value, present := validator.field(key); present {
+			size += len(key) + len(value) + 2
+		}
+	}
+
+	signableString := bytes.NewBuffer(make([]byte, 0, size))
+	validator.writeSignableString(signableString)
+	return signableString.Bytes()
+}
+
+// certParser is implemented by the CertFetchers caching the parsed signing
+// certificates, so that a cached certificate is not parsed on every message
+type certParser interface {
+	parseCertificate(certURL string, certData []byte) (*x509.Certificate, error)
+}
+
+// certURLTruster is implemented by the CertFetchers caching the signing
+// certificates, so that the URL of a cached certificate is not parsed and
+// matched against the same host pattern on every message
+type certURLTruster interface {
+	// trustsCertURL returns whether the certificate URL is cached as trusted
+	// by the host pattern
+	trustsCertURL(certURL string, hostPattern *regexp.Regexp) bool
+	// trustCertURL records that the host pattern trusts the URL of a cached
+	// certificate
+	trustCertURL(certURL string, hostPattern *regexp.Regexp)
+}
+
+// parseCertificate parses the signing certificate of the URL retrieved by the
+// CertFetcher, from the cache of the CertFetcher if it has one
+func (validator *SNSValidator) parseCertificate(certURL string, certData []byte) (*x509.Certificate, error) {
+	if parser, ok := validator.CertFetcher.(certParser); ok {
+		return parser.parseCertificate(certURL, certData)
+	}
+	return parseCertificate(certData)
+}
+
+// parseCertificate parses the PEM encoded certificate. It returns
+// errUndecodableCert if the certificate is not PEM encoded
+func parseCertificate(certData []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return nil, errUndecodableCert
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
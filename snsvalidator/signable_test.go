@@ -0,0 +1,112 @@
+package snsvalidator
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSignatureBuffers(t *testing.T) {
+	Convey("Given the signature buffers of a message", t, func() {
+		message := newValidSignatureMessage()
+		message["Message"] = strings.Repeat("Long notification ", 100)
+		validator := New(message)
+		buffers := getSignatureBuffers()
+		defer putSignatureBuffers(buffers)
+
+		Convey("It should digest the signable string streamed in chunks", func() {
+			for _, hashFunc := range []crypto.Hash{crypto.SHA1, crypto.SHA256} {
+				expected := hashFunc.New()
+				expected.Write(validator.buildSignableString())
+
+				So(buffers.digestSignableString(validator, hashFunc), ShouldResemble, expected.Sum(nil))
+			}
+		})
+
+		Convey("It should decode the signature", func() {
+			signature, err := buffers.decodeSignature(message["Signature"])
+			expected, _ := base64.StdEncoding.DecodeString(message["Signature"])
+
+			So(err, ShouldBeNil)
+			So(signature, ShouldResemble, expected)
+		})
+
+		Convey("It should write the signable string without allocating", func() {
+			writer := &discardStringWriter{}
+			allocs := testing.AllocsPerRun(100, func() {
+				validator.writeSignableString(writer)
+			})
+
+			So(allocs, ShouldEqual, 0)
+			So(writer.written, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("It should digest the signable string without allocating once warm", func() {
+			for _, hashFunc := range []crypto.Hash{crypto.SHA1, crypto.SHA256} {
+				allocs := testing.AllocsPerRun(100, func() {
+					buffers.digestSignableString(validator, hashFunc)
+				})
+
+				So(allocs, ShouldEqual, 0)
+			}
+		})
+
+		Convey("It should decode the signature without allocating once warm", func() {
+			allocs := testing.AllocsPerRun(100, func() {
+				buffers.decodeSignature(message["Signature"])
+			})
+
+			So(allocs, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestCachingCertFetcherParseCertificate(t *testing.T) {
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+
+	Convey("Given a cached certificate", t, func() {
+		cache := newCachingCertFetcher(staticCertFetcher(certData), DefaultCertCacheTTL, 0, NopObserver{})
+		certURL := "https://sns.ap-northeast-1.amazonaws.com/cert.pem"
+		cached, _ := cache.FetchCertificate(context.Background(), certURL)
+
+		Convey("It should parse it once", func() {
+			first, err := cache.parseCertificate(certURL, cached)
+			So(err, ShouldBeNil)
+
+			second, _ := cache.parseCertificate(certURL, cached)
+			So(second, ShouldEqual, first)
+		})
+
+		Convey("It should not return it for other certificate data", func() {
+			first, _ := cache.parseCertificate(certURL, cached)
+
+			_, err := cache.parseCertificate(certURL, []byte("not a certificate"))
+			So(err, ShouldEqual, errUndecodableCert)
+
+			second, _ := cache.parseCertificate(certURL, cached)
+			So(second, ShouldEqual, first)
+		})
+	})
+}
+
+// discardStringWriter counts the bytes written to it
+type discardStringWriter struct {
+	written int
+}
+
+func (writer *discardStringWriter) WriteString(s string) (int, error) {
+	writer.written += len(s)
+	return len(s), nil
+}
+
+// staticCertFetcher returns the certificate data for any URL
+type staticCertFetcher []byte
+
+func (certData staticCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	return certData, nil
+}
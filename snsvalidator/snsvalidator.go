@@ -4,29 +4,97 @@ package snsvalidator
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
 	"crypto/x509"
-	"encoding/base64"
-	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"time"
 
 	"github.com/yuhlau/go-sns-message-validator/snserrors"
+	"github.com/yuhlau/go-sns-message-validator/snsmodel"
 )
 
+// Types of SNS message, same as the types of snsmodel
 const (
-	TypeNotification             = "Notification"
-	TypeSubscriptionConfirmation = "SubscriptionConfirmation"
-	TypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+	TypeNotification             = snsmodel.TypeNotification
+	TypeSubscriptionConfirmation = snsmodel.TypeSubscriptionConfirmation
+	TypeUnsubscribeConfirmation  = snsmodel.TypeUnsubscribeConfirmation
 )
 
+// Types of SNSError returned by the validator
 const (
-	ErrMissingKey         = "MissingKey"
-	ErrInvalidType        = "InvalidType"
-	ErrInvalidCert        = "InvalidCert"
-	ErrIncorrectSignature = "IncorrectSignature"
+	ErrTypeMissingKey         = "MissingKey"
+	ErrTypeInvalidType        = "InvalidType"
+	ErrTypeInvalidCert        = "InvalidCert"
+	ErrTypeIncorrectSignature = "IncorrectSignature"
+	ErrTypeInvalidURL         = "InvalidURL"
+	ErrTypeInvalidTimestamp   = "InvalidTimestamp"
+)
+
+// Sentinel errors of the types of SNSError returned by the validator. Use
+// errors.Is to check the type of a returned error, e.g.
+// errors.Is(err, ErrIncorrectSignature)
+var (
+	ErrMissingKey         = snserrors.New(ErrTypeMissingKey, "Missing key in SNS message")
+	ErrInvalidType        = snserrors.New(ErrTypeInvalidType, "Invalid SNS message type")
+	ErrInvalidCert        = snserrors.New(ErrTypeInvalidCert, "Invalid signing certificate")
+	ErrIncorrectSignature = snserrors.New(ErrTypeIncorrectSignature, "Incorrect signature")
+	ErrInvalidURL         = snserrors.New(ErrTypeInvalidURL, "Invalid URL in SNS message")
+	ErrInvalidTimestamp   = snserrors.New(ErrTypeInvalidTimestamp, "Invalid timestamp in SNS message")
+)
+
+// Machine-readable codes of SNSError returned by the validator, which tell
+// apart the errors of the same type
+const (
+	CodeMissingKey         = "missing_key"
+	CodeInvalidType        = "invalid_type"
+	CodeMalformedCertURL   = "malformed_cert_url"
+	CodeInsecureCertURL    = "insecure_cert_url"
+	CodeUntrustedCertHost  = "untrusted_cert_host"
+	CodeCertUnavailable    = "cert_unavailable"
+	CodeMalformedCert      = "malformed_cert"
+	CodeMalformedSignature = "malformed_signature"
+	CodeSignatureMismatch  = "signature_mismatch"
+	CodeMalformedURL       = "malformed_url"
+	CodeMalformedTimestamp = "malformed_timestamp"
+
+	CodeUnsupportedSignatureVersion = "unsupported_signature_version"
+	CodeExpiredMessage              = "expired_message"
+)
+
+// Signature versions of SNS message
+// Version 1 signs the message with SHA1WithRSA
+// Version 2 signs the message with SHA256WithRSA
+const (
+	SignatureVersion1 = "1"
+	SignatureVersion2 = "2"
+)
+
+// Hash functions of the RSA signatures of the signature versions
+var signatureHashes = map[string]crypto.Hash{
+	SignatureVersion1: crypto.SHA1,
+	SignatureVersion2: crypto.SHA256,
+}
+
+// Steps of verifying the signature, in order
+const (
+	StepSignatureVersion = "signature_version"
+	StepCertURL          = "cert_url"
+	StepFetchCert        = "fetch_cert"
+	StepParseCert        = "parse_cert"
+	StepDecodeSignature  = "decode_signature"
+	StepCheckSignature   = "check_signature"
 )
 
 // List of AWS Signing Certificate URL trustable hosts
@@ -42,20 +110,20 @@ var defaultHostPatternRegexp = regexp.MustCompile(defaultHostPattern)
 
 // Required keys of a SNS Message
 var requiredKeys = []string{
-	"Type",
-	"MessageId",
-	"TopicArn",
-	"Message",
-	"Timestamp",
-	"Signature",
-	"SignatureVersion",
-	"SigningCertURL",
+	snsmodel.KeyType,
+	snsmodel.KeyMessageId,
+	snsmodel.KeyTopicArn,
+	snsmodel.KeyMessage,
+	snsmodel.KeyTimestamp,
+	snsmodel.KeySignature,
+	snsmodel.KeySignatureVersion,
+	snsmodel.KeySigningCertURL,
 }
 
 // required keys for SubscriptionConfirmation and UnsubscribeConfirmation
 var requiredSubscriptionKeys = []string{
-	"SubscribeURL",
-	"Token",
+	snsmodel.KeySubscribeURL,
+	snsmodel.KeyToken,
 }
 
 // List of valid message types
@@ -74,31 +142,117 @@ var subscriptionMessageTypes = []string{
 // The order of signable keys must be same when bulding the signable string
 // Signable keys for SubscriptionConfirmation and UnsubscribeConfirmation
 var signableKeysForSubscription = []string{
-	"Message",
-	"MessageId",
+	snsmodel.KeyMessage,
+	snsmodel.KeyMessageId,
 	// "Subject" should not appear in Subscription. Keep here just to be safe
-	"Subject",
-	"SubscribeURL",
-	"Timestamp",
-	"Token",
-	"TopicArn",
-	"Type",
+	snsmodel.KeySubject,
+	snsmodel.KeySubscribeURL,
+	snsmodel.KeyTimestamp,
+	snsmodel.KeyToken,
+	snsmodel.KeyTopicArn,
+	snsmodel.KeyType,
 }
 
 // Signable keys for Notification
+// "SequenceNumber" is only present in messages delivered from a FIFO topic.
+// The other FIFO keys "MessageGroupId" and "MessageDeduplicationId" are not
+// part of the signature
 var signableKeysForNotification = []string{
-	"Message",
-	"MessageId",
-	"Subject", // if included in the message
-	"SubscribeURL",
-	"Timestamp",
-	"TopicArn",
-	"Type",
+	snsmodel.KeyMessage,
+	snsmodel.KeyMessageId,
+	snsmodel.KeySequenceNumber, // if included in the message
+	snsmodel.KeySubject,        // if included in the message
+	snsmodel.KeySubscribeURL,
+	snsmodel.KeyTimestamp,
+	snsmodel.KeyTopicArn,
+	snsmodel.KeyType,
+}
+
+// Message is a SNS message to be validated
+type Message interface {
+	// Field returns the value of the key of the SNS message and whether the
+	// key is present. The key is one of the SNS message keys, e.g. "Subject".
+	// A key may be present with empty value
+	Field(key string) (string, bool)
 }
 
+// snsmodel.Message is the Message of the SNS message model
+var _ Message = (*snsmodel.Message)(nil)
+
 type SNSValidator struct {
-	Version    int
+	Version int
+	// Message is the underlying SNS message. If it is nil, MessageMap is used
+	Message Message
+	// MessageMap is the underlying SNS message as a map. A key with empty
+	// value is considered absent, use Message to validate a message with
+	// empty but present keys
 	MessageMap map[string]string
+
+	// HTTPClient retrieves the signing certificate. If it is nil,
+	// http.DefaultClient is used
+	HTTPClient *http.Client
+	// HostPattern matches the hostnames of the trusted signing certificate
+	// URLs. If it is nil, only the AWS SNS hosts are trusted
+	HostPattern *regexp.Regexp
+	// CertFetcher retrieves the signing certificate. If it is nil, the
+	// certificate is retrieved from "SigningCertURL" with HTTPClient
+	CertFetcher CertFetcher
+	// MaxAge is the maximum age of the message by its "Timestamp". If it is
+	// zero, the age is not checked
+	MaxAge time.Duration
+	// SignatureVersions lists the accepted signature versions. If it is empty,
+	// all the supported signature versions are accepted
+	SignatureVersions []string
+	// Logger logs the retrieval of the signing certificate and the
+	// verification of the signature, with debug records of each step and a
+	// warn record on failure. The "Message" and "Signature" are never
+	// logged. If it is nil, nothing is logged
+	Logger *slog.Logger
+	// Tracer starts the spans of the validation steps, see the Span
+	// constants. If it is nil, the validation is not traced
+	Tracer Tracer
+
+	// verifications caches the successful signature verifications of the
+	// Verifier, see WithVerificationCache
+	verifications *verificationCache
+}
+
+// CertFetcher retrieves the signing certificate of the URL
+type CertFetcher interface {
+	FetchCertificate(ctx context.Context, certURL string) ([]byte, error)
+}
+
+// HTTPCertFetcher retrieves the signing certificate from its URL with the
+// Client. If Client is nil, http.DefaultClient is used
+type HTTPCertFetcher struct {
+	Client *http.Client
+}
+
+var _ StatusCertFetcher = HTTPCertFetcher{}
+
+// StatusCertFetcher is implemented by the CertFetchers retrieving the signing
+// certificates with HTTP, so that the HTTP status code of the response is
+// reported to Observer.CertFetched
+type StatusCertFetcher interface {
+	CertFetcher
+	// FetchCertificateStatus retrieves the signing certificate like
+	// FetchCertificate, and also returns the HTTP status code of the
+	// response, 0 if no response is received
+	FetchCertificateStatus(ctx context.Context, certURL string) ([]byte, int, error)
+}
+
+// DirCertFetcher retrieves the signing certificates from the directory, where
+// each certificate is stored in the file named by the last path segment of
+// its URL, e.g. SimpleNotificationService-0000000000000000000000.pem. It never
+// accesses the network.
+type DirCertFetcher string
+
+// New returns a new version 1 SNSValidator of the message.
+func New(message Message) *SNSValidator {
+	return &SNSValidator{
+		Version: 1,
+		Message: message,
+	}
 }
 
 // NewV1 returns a new version 1 SNSValiator with the specified map as the
@@ -119,26 +273,64 @@ func NewV1(messageMap map[string]string) *SNSValidator {
 // ErrInvalidCert
 // If the signature is incorrect, it returns SNSError of ErrIncorrectSignature
 func (validator *SNSValidator) ValidateMessage() error {
-	if err := validator.validateMessageStructure(); err != nil {
+	return validator.ValidateMessageContext(context.Background())
+}
+
+// ValidateMessageContext is like ValidateMessage, but retrieves the
+// certificate with the given context, e.g. the context of the incoming HTTP
+// request.
+// If the certificate cannot be retrieved because of a network failure, a 5xx
+// response or the context being done, the SNSError of type ErrInvalidCert is
+// temporary, as reported by snserrors.IsTemporary. Any other error is
+// permanent.
+func (validator *SNSValidator) ValidateMessageContext(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, validator.Tracer, SpanValidate)
+	span.SetAttribute(AttrMessageId, validator.value("MessageId"))
+	span.SetAttribute(AttrTopicArn, validator.value("TopicArn"))
+	span.SetAttribute(AttrMessageType, validator.value("Type"))
+	defer func() { span.End(err) }()
+
+	_, structureSpan := startSpan(ctx, validator.Tracer, SpanValidateStructure)
+	err = validator.validateMessageStructure()
+	structureSpan.End(err)
+	if err != nil {
 		return err
 	}
 
-	if err := validator.verifySignature(); err != nil {
+	if err := validator.validateAge(); err != nil {
+		return err
+	}
+
+	if err := validator.verifySignature(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// field returns the value of the key of the underlying SNS message and
+// whether the key is present.
+func (validator *SNSValidator) field(key string) (string, bool) {
+	if validator.Message != nil {
+		return validator.Message.Field(key)
+	}
+	value := validator.MessageMap[key]
+	return value, value != ""
+}
+
+// value returns the value of the key of the underlying SNS message, or empty
+// string if the key is absent.
+func (validator *SNSValidator) value(key string) string {
+	value, _ := validator.field(key)
+	return value
+}
+
 // has returns boolean on whether the underlying SNS message has the key
-// specified.
-// Since json.Unmsrahsl() will leave missing key with a zero value - empty
-// string. The method will also check for non-empty string.
+// specified with non-empty value. A present but empty key is treated as
+// missing in validation, only the signable string includes it.
 func (validator *SNSValidator) has(key string) bool {
-	if value, exists := validator.MessageMap[key]; exists && value != "" {
-		return true
-	}
-	return false
+	value, present := validator.field(key)
+	return present && value != ""
 }
 
 // hasKeys returns boolean on whether the underlying SNS message map has all
@@ -157,7 +349,7 @@ func (validator *SNSValidator) hasKeys(keylist []string) (bool, string) {
 // isTypes returns boolean on whether the underlying SNS message is one of the
 // specified types.
 func (validator *SNSValidator) isTypes(typelist []string) bool {
-	messageType := validator.MessageMap["Type"]
+	messageType := validator.value("Type")
 	for _, t := range typelist {
 		if messageType == t {
 			return true
@@ -172,14 +364,20 @@ func (validator *SNSValidator) isTypes(typelist []string) bool {
 // type ErrMissingKey.
 func (validator *SNSValidator) validateRequiredKeys() error {
 	if valid, missingKey := validator.hasKeys(requiredKeys); !valid {
-		return snserrors.New(
-			ErrMissingKey,
-			fmt.Sprintf("\"%s\" is required in SNS message", missingKey),
-		)
+		return validator.missingKeyError(missingKey, "SNS")
 	}
 	return nil
 }
 
+// missingKeyError returns an SNSError of type ErrMissingKey about the key
+// missing in the kind of message
+func (validator *SNSValidator) missingKeyError(key string, kind string) error {
+	return snserrors.New(
+		ErrTypeMissingKey,
+		fmt.Sprintf("\"%s\" is required in %s message", key, kind),
+	).WithCode(CodeMissingKey).WithDetails(validator.keyDetails(key))
+}
+
 // validateMessageType validates the underlying SNS message is one of the valid
 // message types.
 // If the message is none of the valid types, it returns an SNSError of type
@@ -187,9 +385,9 @@ func (validator *SNSValidator) validateRequiredKeys() error {
 func (validator *SNSValidator) validateMessageType() error {
 	if !validator.isTypes(validMessageTypes) {
 		return snserrors.New(
-			ErrInvalidType,
-			fmt.Sprintf("Invalid message type \"%s\"", validator.MessageMap["Type"]),
-		)
+			ErrTypeInvalidType,
+			fmt.Sprintf("Invalid message type \"%s\"", validator.value("Type")),
+		).WithCode(CodeInvalidType).WithDetails(validator.keyDetails("Type"))
 	}
 	return nil
 }
@@ -200,10 +398,7 @@ func (validator *SNSValidator) validateMessageType() error {
 // type ErrMissingKey.
 func (validator *SNSValidator) validateSubscriptionKeys() error {
 	if valid, missingKey := validator.hasKeys(requiredSubscriptionKeys); !valid {
-		return snserrors.New(
-			ErrMissingKey,
-			fmt.Sprintf("\"%s\" is required in Subscription message", missingKey),
-		)
+		return validator.missingKeyError(missingKey, "Subscription")
 	}
 	return nil
 }
@@ -233,102 +428,481 @@ func (validator *SNSValidator) validateMessageStructure() error {
 	return nil
 }
 
-// buildSignableString returns signable string of the underlying SNS message.
-// The signable string is essential to verify the signature.
-func (validator *SNSValidator) buildSignableString() []byte {
-	// 1000 bytes is a heuristic length for a signable strings
-	signableString := bytes.NewBuffer(make([]byte, 0, 1000))
+// validateURL validates the URL of the key in the underlying SNS message is an
+// absolute URL, if the key is present.
+// If the URL is malformed, it returns an SNSError of type ErrInvalidURL.
+func (validator *SNSValidator) validateURL(key string) error {
+	if !validator.has(key) {
+		return nil
+	}
+
+	parsedUrl, err := url.Parse(validator.value(key))
+	if err == nil && (!parsedUrl.IsAbs() || parsedUrl.Host == "") {
+		err = fmt.Errorf("\"%s\" is not an absolute URL", key)
+	}
+	if err != nil {
+		return snserrors.Wrap(ErrTypeInvalidURL, err, "").
+			WithCode(CodeMalformedURL).WithDetails(validator.keyDetails(key))
+	}
+	return nil
+}
+
+// validateTimestamp validates the "Timestamp" of the underlying SNS message is
+// in RFC 3339 format, if it is present.
+// If the timestamp is malformed, it returns an SNSError of type
+// ErrInvalidTimestamp.
+func (validator *SNSValidator) validateTimestamp() error {
+	if !validator.has("Timestamp") {
+		return nil
+	}
+
+	if _, err := time.Parse(time.RFC3339, validator.value("Timestamp")); err != nil {
+		return snserrors.Wrap(ErrTypeInvalidTimestamp, err, "").
+			WithCode(CodeMalformedTimestamp).WithDetails(validator.keyDetails("Timestamp"))
+	}
+	return nil
+}
+
+// validateAge validates the underlying SNS message is not older than MaxAge,
+// if MaxAge is set and the "Timestamp" is present.
+// If the timestamp is malformed or too old, it returns an SNSError of type
+// ErrInvalidTimestamp.
+func (validator *SNSValidator) validateAge() error {
+	if validator.MaxAge <= 0 || !validator.has("Timestamp") {
+		return nil
+	}
+
+	if err := validator.validateTimestamp(); err != nil {
+		return err
+	}
+
+	timestamp, _ := time.Parse(time.RFC3339, validator.value("Timestamp"))
+	if age := time.Since(timestamp); age > validator.MaxAge {
+		return snserrors.New(
+			ErrTypeInvalidTimestamp,
+			fmt.Sprintf("The message is %v old, older than %v", age.Round(time.Second), validator.MaxAge),
+		).WithCode(CodeExpiredMessage).WithDetails(validator.keyDetails("Timestamp"))
+	}
+	return nil
+}
+
+// DiagnoseMessage validates the underlying SNS message like ValidateMessage,
+// but instead of stopping at the first failure, it reports every missing key,
+// invalid type, malformed URL and malformed timestamp at once. The signature
+// is only verified if nothing else fails.
+// It is meant for diagnosing a broken producer. The URL and timestamp format
+// checks are not part of ValidateMessage.
+// If the message is invalid, it returns *snserrors.MultiError listing the
+// SNSError of each failure.
+func (validator *SNSValidator) DiagnoseMessage() error {
+	return validator.DiagnoseMessageContext(context.Background())
+}
+
+// DiagnoseMessageContext is like DiagnoseMessage, but retrieves the
+// certificate with the given context.
+func (validator *SNSValidator) DiagnoseMessageContext(ctx context.Context) error {
+	var errs []error
+
+	for _, key := range requiredKeys {
+		if !validator.has(key) {
+			errs = append(errs, validator.missingKeyError(key, "SNS"))
+		}
+	}
+
+	if validator.has("Type") {
+		errs = append(errs, validator.validateMessageType())
+	}
 
-	var signableKeys []string
 	if validator.isTypes(subscriptionMessageTypes) {
-		signableKeys = signableKeysForSubscription
-	} else {
-		signableKeys = signableKeysForNotification
-	}
-	for _, key := range signableKeys {
-		// Some keys like "Subject" are included only if it is present
-		if validator.has(key) {
-			signableString.WriteString(key)
-			signableString.WriteString("\n")
-			signableString.WriteString(validator.MessageMap[key])
-			signableString.WriteString("\n")
+		for _, key := range requiredSubscriptionKeys {
+			if !validator.has(key) {
+				errs = append(errs, validator.missingKeyError(key, "Subscription"))
+			}
 		}
 	}
-	return signableString.Bytes()
+
+	if validator.has("SigningCertURL") {
+		errs = append(errs, validator.validateCertURL())
+	}
+	errs = append(errs, validator.validateURL("SubscribeURL"))
+	errs = append(errs, validator.validateURL("UnsubscribeURL"))
+	if err := validator.validateTimestamp(); err != nil {
+		errs = append(errs, err)
+	} else {
+		errs = append(errs, validator.validateAge())
+	}
+
+	if snserrors.Join(errs...) == nil {
+		errs = append(errs, validator.verifySignature(ctx))
+	}
+
+	return snserrors.Join(errs...)
+}
+
+// SignableString returns the signable string of the underlying SNS message,
+// which is the data signed by the "Signature" of the message.
+func (validator *SNSValidator) SignableString() []byte {
+	return validator.buildSignableString()
 }
 
 // getCertificate tries to fetch the Signing Certificate that is used to sign
-// the underlying SNS message, and returns the certifcate in slice of bytes.
-// If the HTTP request fails, it also returns a SNSError of type ErrInvalidCert
-// describing the error
-func (validator *SNSValidator) getCertificate() ([]byte, error) {
-	res, err := http.Get(validator.MessageMap["SigningCertURL"])
+// the underlying SNS message with the CertFetcher, and returns the certifcate
+// in slice of bytes.
+// If the certificate cannot be retrieved, it also returns a SNSError of type
+// ErrInvalidCert describing the error
+func (validator *SNSValidator) getCertificate(ctx context.Context) (_ []byte, err error) {
+	fetcher := validator.CertFetcher
+	if fetcher == nil {
+		fetcher = HTTPCertFetcher{Client: validator.HTTPClient}
+	}
+
+	certURL := validator.value("SigningCertURL")
+	ctx, span := startSpan(ctx, validator.Tracer, SpanResolveCertificate)
+	span.SetAttribute(AttrCertURL, certURL)
+	defer func() { span.End(err) }()
+
+	start := time.Now()
+	certData, err := fetcher.FetchCertificate(ctx, certURL)
+	if validator.Logger != nil {
+		attrs := []slog.Attr{slog.String("cert_url", certURL), slog.Duration("duration", time.Since(start))}
+		if err == nil {
+			validator.log(ctx, slog.LevelDebug, "Retrieved the signing certificate", attrs...)
+		} else {
+			// The failure is logged as warning by verifySignature
+			validator.log(ctx, slog.LevelDebug, "Failed to retrieve the signing certificate", append(attrs, errorAttrs(err)...)...)
+		}
+	}
+	if err == nil {
+		return certData, nil
+	}
+
+	details := validator.keyDetails("SigningCertURL")
+	if snsErr, ok := err.(*snserrors.SNSError); ok {
+		details.StatusCode = snsErr.Details().StatusCode
+		return nil, snsErr.WithDetails(details)
+	}
+	return nil, snserrors.Wrap(ErrTypeInvalidCert, err, "").
+		WithCode(CodeCertUnavailable).WithDetails(details)
+}
+
+// FetchCertificate retrieves the signing certificate from the URL.
+// If the HTTP request fails, it returns a SNSError of type ErrInvalidCert
+// describing the error. The error is temporary if the request could not be
+// completed or the host responded with a 5xx status code
+func (fetcher HTTPCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	certData, _, err := fetcher.FetchCertificateStatus(ctx, certURL)
+	return certData, err
+}
+
+// FetchCertificateStatus retrieves the signing certificate from the URL like
+// FetchCertificate, and also returns the HTTP status code of the response, 0
+// if no response is received
+func (fetcher HTTPCertFetcher) FetchCertificateStatus(ctx context.Context, certURL string) ([]byte, int, error) {
+	details := snserrors.Details{CertURL: certURL}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, 0, snserrors.Wrap(ErrTypeInvalidCert, err, "").
+			WithCode(CodeMalformedCertURL).WithDetails(details)
+	}
+
+	client := fetcher.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
 	if err != nil {
-		return nil, snserrors.New(ErrInvalidCert, err.Error())
+		return nil, 0, snserrors.Wrap(ErrTypeInvalidCert, err, "").
+			WithCode(CodeCertUnavailable).WithDetails(details).WithTemporary(true)
 	}
 	defer res.Body.Close()
 
+	details.StatusCode = res.StatusCode
 	if res.StatusCode != 200 {
-		return nil, snserrors.New(ErrInvalidCert, "Could not retrive the certificate")
+		return nil, res.StatusCode, snserrors.New(ErrTypeInvalidCert, "Could not retrive the certificate").
+			WithCode(CodeCertUnavailable).WithDetails(details).
+			WithTemporary(res.StatusCode >= 500)
 	}
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, snserrors.New(ErrInvalidCert, err.Error())
+		return nil, res.StatusCode, snserrors.Wrap(ErrTypeInvalidCert, err, "").
+			WithCode(CodeCertUnavailable).WithDetails(details).WithTemporary(true)
 	}
 
-	return body, nil
+	return body, res.StatusCode, nil
 }
 
-// verifySignature verifieds the underlying SNS message signature is correct.
-// If the certificate cannot be retrieved, it returns SNSError of type
+// FetchCertificate reads the signing certificate of the URL from the
+// directory.
+// If the certificate cannot be read, it returns a SNSError of type
+// ErrInvalidCert describing the error
+func (dir DirCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	details := snserrors.Details{CertURL: certURL}
+
+	parsedUrl, err := url.Parse(certURL)
+	if err != nil {
+		return nil, snserrors.Wrap(ErrTypeInvalidCert, err, "").
+			WithCode(CodeMalformedCertURL).WithDetails(details)
+	}
+
+	name := path.Base(parsedUrl.Path)
+	if name == "/" || name == "." {
+		return nil, snserrors.New(ErrTypeInvalidCert, "The certificate URL has no file name").
+			WithCode(CodeMalformedCertURL).WithDetails(details)
+	}
+
+	certData, err := os.ReadFile(filepath.Join(string(dir), name))
+	if err != nil {
+		return nil, snserrors.Wrap(ErrTypeInvalidCert, err, "").
+			WithCode(CodeCertUnavailable).WithDetails(details)
+	}
+	return certData, nil
+}
+
+// validateCertURL validates the "SigningCertURL" of the underlying SNS message
+// is a HTTPS URL of a trusted host.
+// If the URL is malformed or untrusted, it returns SNSError of type
 // ErrInvalidCert
-// If the signature is incorrect, it returns SNSError of type
-// ErrIncorrectSignature
-func (validator *SNSValidator) verifySignature() error {
-	// Verify the SigningCertURL is trustworthy
-	parsedUrl, err := url.Parse(validator.MessageMap["SigningCertURL"])
+func (validator *SNSValidator) validateCertURL() error {
+	certURL := validator.value("SigningCertURL")
+	hostPattern := validator.HostPattern
+	if hostPattern == nil {
+		hostPattern = defaultHostPatternRegexp
+	}
+
+	// The URL of a cached certificate trusted before needs no parsing
+	truster, cached := validator.CertFetcher.(certURLTruster)
+	if cached && truster.trustsCertURL(certURL, hostPattern) {
+		return nil
+	}
+
+	certDetails := validator.keyDetails("SigningCertURL")
+
+	parsedUrl, err := url.Parse(certURL)
 	if err != nil {
-		return snserrors.New(ErrInvalidCert, err.Error())
+		return snserrors.Wrap(ErrTypeInvalidCert, err, "").
+			WithCode(CodeMalformedCertURL).WithDetails(certDetails)
 	}
 
 	if parsedUrl.Scheme != "https" {
-		return snserrors.New(ErrInvalidCert, "The certificate URL is using insecure HTTP scheme")
+		return snserrors.New(ErrTypeInvalidCert, "The certificate URL is using insecure HTTP scheme").
+			WithCode(CodeInsecureCertURL).WithDetails(certDetails)
 	}
 
-	if match := defaultHostPatternRegexp.MatchString(
+	if match := hostPattern.MatchString(
 		parsedUrl.Hostname(),
 	); !match {
-		return snserrors.New(ErrInvalidCert, "The certificate URL belongs to an untrusted host")
+		return snserrors.New(ErrTypeInvalidCert, "The certificate URL belongs to an untrusted host").
+			WithCode(CodeUntrustedCertHost).WithDetails(certDetails)
+	}
+
+	if cached {
+		truster.trustCertURL(certURL, hostPattern)
+	}
+	return nil
+}
+
+// SignatureExplanation records the steps of verifying the signature of a SNS
+// message, for debugging a signature failure
+type SignatureExplanation struct {
+	// SignableString is the data signed by the signature
+	SignableString []byte
+	// SignatureVersion is the "SignatureVersion" of the message
+	SignatureVersion string
+	// Hash is the hash function of the signature version, zero if the
+	// signature version is unsupported
+	Hash crypto.Hash
+	// Digest is the hash of SignableString, nil if the signature version is
+	// unsupported
+	Digest []byte
+	// SignatureLength is the length of the base64-decoded signature, -1 if it
+	// cannot be decoded
+	SignatureLength int
+	// Certificate is the signing certificate, nil if it is not retrieved
+	Certificate *x509.Certificate
+	// FailedStep is the step failing the verification, one of the Step
+	// constants, or empty if the signature is correct
+	FailedStep string
+	// Err is the error of the failed step, nil if the signature is correct
+	Err error
+}
+
+// ExplainSignature verifies the underlying SNS message signature like
+// ValidateMessage does, and records the data of each step up to the failed
+// one. The signable string, digest and signature length are recorded
+// regardless of the failed step.
+func (validator *SNSValidator) ExplainSignature(ctx context.Context) *SignatureExplanation {
+	explanation := &SignatureExplanation{SignableString: validator.buildSignableString()}
+
+	buffers := getSignatureBuffers()
+	defer putSignatureBuffers(buffers)
+
+	validator.checkSignature(ctx, explanation, buffers)
+	// The digest is in the buffers put back to the pool
+	explanation.Digest = bytes.Clone(explanation.Digest)
+	return explanation
+}
+
+// checkSignature verifies the underlying SNS message signature and records
+// the steps to the explanation, except the signable string which is streamed
+// into the hash. The digest recorded is in the buffers.
+func (validator *SNSValidator) checkSignature(
+	ctx context.Context, explanation *SignatureExplanation, buffers *signatureBuffers,
+) {
+	explanation.SignatureVersion = validator.value("SignatureVersion")
+	explanation.SignatureLength = -1
+
+	hash, supported := signatureHashes[explanation.SignatureVersion]
+	if supported {
+		explanation.Hash = hash
+		explanation.Digest = buffers.digestSignableString(validator, hash)
+	}
+
+	decodedSignature, decodeErr := buffers.decodeSignature(validator.value("Signature"))
+	if decodeErr == nil {
+		explanation.SignatureLength = len(decodedSignature)
+	}
+
+	fail := func(step string, err error) {
+		explanation.FailedStep = step
+		explanation.Err = err
+	}
+
+	if supported && len(validator.SignatureVersions) > 0 {
+		supported = false
+		for _, accepted := range validator.SignatureVersions {
+			if explanation.SignatureVersion == accepted {
+				supported = true
+			}
+		}
+	}
+	if !supported {
+		fail(StepSignatureVersion, snserrors.New(
+			ErrTypeIncorrectSignature,
+			fmt.Sprintf("Unsupported signature version \"%s\"", explanation.SignatureVersion),
+		).WithCode(CodeUnsupportedSignatureVersion).WithDetails(validator.keyDetails("SignatureVersion")))
+		return
+	}
+
+	// Verify the SigningCertURL is trustworthy
+	if err := validator.validateCertURL(); err != nil {
+		fail(StepCertURL, err)
+		return
 	}
 
 	// Obtain the signing certificate
-	certData, snserr := validator.getCertificate()
+	certData, snserr := validator.getCertificate(ctx)
 	if snserr != nil {
-		return snserr
+		fail(StepFetchCert, snserr)
+		return
 	}
 
-	block, _ := pem.Decode(certData)
-	if block == nil {
-		return snserrors.New(ErrInvalidCert, "Could not decode the certificate")
+	cert, err := validator.parseCertificate(validator.value("SigningCertURL"), certData)
+	if err == errUndecodableCert {
+		fail(StepParseCert, snserrors.New(ErrTypeInvalidCert, err.Error()).
+			WithCode(CodeMalformedCert).WithDetails(validator.keyDetails("SigningCertURL")))
+		return
 	}
-
-	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		return snserrors.New(ErrInvalidCert, err.Error())
+		fail(StepParseCert, snserrors.Wrap(ErrTypeInvalidCert, err, "").
+			WithCode(CodeMalformedCert).WithDetails(validator.keyDetails("SigningCertURL")))
+		return
+	}
+	explanation.Certificate = cert
+
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		fail(StepParseCert, snserrors.New(ErrTypeInvalidCert, "The certificate does not have a RSA public key").
+			WithCode(CodeMalformedCert).WithDetails(validator.keyDetails("SigningCertURL")))
+		return
 	}
 
 	// base64 decode the signature given
-	decodedSignature, err := base64.StdEncoding.DecodeString(validator.MessageMap["Signature"])
-	if err != nil {
-		return snserrors.New(ErrIncorrectSignature, "Could not base64 decode the signature")
+	if decodeErr != nil {
+		fail(StepDecodeSignature, snserrors.Wrap(ErrTypeIncorrectSignature, decodeErr, "Could not base64 decode the signature").
+			WithCode(CodeMalformedSignature).WithDetails(validator.keyDetails("Signature")))
+		return
+	}
+
+	// A signature verified before needs no RSA verification
+	var key verificationKey
+	if validator.verifications != nil {
+		key = newVerificationKey(cert, hash, explanation.Digest, decodedSignature)
+		if validator.verifications.contains(key) {
+			spanFromContext(ctx).SetAttribute(AttrVerificationCache, VerificationCacheHit)
+			return
+		}
+		spanFromContext(ctx).SetAttribute(AttrVerificationCache, VerificationCacheMiss)
 	}
 
 	// check for the validitly of signature
-	if err := cert.CheckSignature(
-		x509.SHA1WithRSA, validator.buildSignableString(), decodedSignature,
-	); err != nil {
-		return snserrors.New(ErrIncorrectSignature, fmt.Sprintf("Incorrect signature: %v", err))
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, explanation.Digest, decodedSignature); err != nil {
+		fail(StepCheckSignature, snserrors.Wrap(ErrTypeIncorrectSignature, err, fmt.Sprintf("Incorrect signature: %v", err)).
+			WithCode(CodeSignatureMismatch).WithDetails(validator.keyDetails("Signature")))
+		return
+	}
+
+	if validator.verifications != nil {
+		validator.verifications.add(key)
 	}
+}
+
+// verifySignature verifieds the underlying SNS message signature is correct.
+// If the certificate cannot be retrieved, it returns SNSError of type
+// ErrInvalidCert
+// If the signature version is unsupported or the signature is incorrect, it
+// returns SNSError of type ErrIncorrectSignature
+//
+// Unlike ExplainSignature, it streams the signable string into the hash and
+// decodes the signature into pooled buffers, without allocating. When the
+// signing certificate is in the certificate cache of a Verifier, neither the
+// certificate nor its URL is parsed again. Only the RSA verification in
+// crypto/rsa allocates then, so with WithVerificationCache a message verified
+// before is verified again without allocating, unless a Logger or a Tracer is
+// set.
+func (validator *SNSValidator) verifySignature(ctx context.Context) error {
+	ctx, span := startSpan(ctx, validator.Tracer, SpanVerifySignature)
+	start := time.Now()
+
+	buffers := getSignatureBuffers()
+	var explanation SignatureExplanation
+	validator.checkSignature(ctx, &explanation, buffers)
+	putSignatureBuffers(buffers)
+
+	span.SetAttribute(AttrSignatureVersion, explanation.SignatureVersion)
+	span.End(explanation.Err)
+
+	if validator.Logger == nil {
+		return explanation.Err
+	}
+
+	attrs := []slog.Attr{
+		slog.String("cert_url", validator.value("SigningCertURL")),
+		slog.String("signature_version", explanation.SignatureVersion),
+		slog.Duration("duration", time.Since(start)),
+	}
+
+	if err := explanation.Err; err != nil {
+		attrs = append(attrs, slog.String("step", explanation.FailedStep))
+		validator.log(ctx, slog.LevelWarn, "Failed to verify the signature", append(attrs, errorAttrs(err)...)...)
+		return err
+	}
+	validator.log(ctx, slog.LevelDebug, "Verified the signature", attrs...)
 	return nil
 }
+
+// keyDetails returns the structured details of an error about the key of the
+// underlying SNS message. The signing certificate URL is included for errors
+// about the certificate or the signature.
+func (validator *SNSValidator) keyDetails(key string) snserrors.Details {
+	details := snserrors.Details{
+		Key:         key,
+		MessageType: validator.value("Type"),
+	}
+	if key == "SigningCertURL" || key == "Signature" {
+		details.CertURL = validator.value("SigningCertURL")
+	}
+	return details
+}
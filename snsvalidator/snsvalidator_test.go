@@ -1,7 +1,16 @@
 package snsvalidator
 
 import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"gopkg.in/h2non/gock.v1"
@@ -96,6 +105,20 @@ func TestNewV1(t *testing.T) {
 	})
 }
 
+func TestNew(t *testing.T) {
+	Convey("Given a SNS message", t, func() {
+		message := mapMessage{"Type": "Notification", "Message": "Test notification"}
+
+		Convey("It should return version 1 SNS Validator of the message", func() {
+			actual := New(message)
+
+			So(actual.Version, ShouldEqual, 1)
+			So(actual.Message, ShouldResemble, message)
+			So(actual.MessageMap, ShouldBeNil)
+		})
+	})
+}
+
 func TestValidateMessageMethod(t *testing.T) {
 	// Mock HTTP request-response
 	certData := `-----BEGIN CERTIFICATE-----
@@ -144,7 +167,7 @@ kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrMissingKey", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrMissingKey)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMissingKey)
 			})
 			Convey("Returned SNSError message should be about the missing \"Message\" key", func() {
 				So(actual.Error(), ShouldEqual, "\"Message\" is required in SNS message")
@@ -177,7 +200,7 @@ kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 		})
 	})
@@ -365,11 +388,18 @@ func TestValidateRequiredKeysMethod(t *testing.T) {
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrMissingKey", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrMissingKey)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMissingKey)
 			})
 			Convey("Returned SNSError message should be about the missing \"Message\" key", func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, "\"Message\" is required in SNS message")
 			})
+			Convey("Returned SNSError should have the code and details of the missing key", func() {
+				So(actual.(*snserrors.SNSError).Code(), ShouldEqual, CodeMissingKey)
+				So(actual.(*snserrors.SNSError).Details(), ShouldResemble, snserrors.Details{
+					Key:         "Message",
+					MessageType: "SubscriptionConfirmation",
+				})
+			})
 		})
 	})
 
@@ -434,7 +464,7 @@ func TestValidateMessageTypeMethod(t *testing.T) {
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrInvalidType", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidType)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidType)
 			})
 			Convey("Returned SNSError message should be about the invalid type", func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, `Invalid message type "InvalidType"`)
@@ -505,7 +535,7 @@ func TestValidateSubscriptionKeysMethod(t *testing.T) {
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrMissingKey", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrMissingKey)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMissingKey)
 			})
 			Convey(`Returned SNSError message should be about the missing "SubscribeURL" key`, func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, `"SubscribeURL" is required in Subscription message`)
@@ -539,7 +569,7 @@ func TestValidateSubscriptionKeysMethod(t *testing.T) {
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrMissingKey", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrMissingKey)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMissingKey)
 			})
 			Convey(`Returned SNSError message should be about the missing "Token" key`, func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, `"Token" is required in Subscription message`)
@@ -610,7 +640,7 @@ func TestValidateMessageStructureMethod(t *testing.T) {
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrMissingKey", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrMissingKey)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMissingKey)
 			})
 			Convey(`Returned SNSError message should be about the missing "Message" key`, func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, `"Message" is required in SNS message`)
@@ -643,7 +673,7 @@ func TestValidateMessageStructureMethod(t *testing.T) {
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrInvalidType", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidType)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidType)
 			})
 			Convey("Returned SNSError message should be about the invalide type", func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, `Invalid message type "InvalidType"`)
@@ -677,7 +707,7 @@ func TestValidateMessageStructureMethod(t *testing.T) {
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 			Convey("Returned SNSError should be of type ErrMissingKey", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrMissingKey)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeMissingKey)
 			})
 			Convey(`Returned SNSError message should be about the missing "SubscribeURL" key`, func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, `"SubscribeURL" is required in Subscription message`)
@@ -722,6 +752,122 @@ func TestValidateMessageStructureMethod(t *testing.T) {
 	})
 }
 
+func TestDiagnoseMessageMethod(t *testing.T) {
+	Convey("Given SNSValidator of message with several failures", t, func() {
+		validator := newSubscriptionMessageValidator()
+		validator.MessageMap["MessageId"] = ""
+		validator.MessageMap["Token"] = ""
+		validator.MessageMap["SigningCertURL"] = "http://sns.us-west-2.amazonaws.com/cert.pem"
+		validator.MessageMap["SubscribeURL"] = "/subscribe"
+		validator.MessageMap["Timestamp"] = "26 Apr 2012 20:45:04"
+
+		Convey("It should return a MultiError of every failure", func() {
+			actual := validator.DiagnoseMessage()
+
+			So(actual, ShouldHaveSameTypeAs, &snserrors.MultiError{})
+			errs := actual.(*snserrors.MultiError).Errors()
+			So(errs, ShouldHaveLength, 5)
+
+			codes := make([]string, len(errs))
+			keys := make([]string, len(errs))
+			for i, err := range errs {
+				codes[i] = err.(*snserrors.SNSError).Code()
+				keys[i] = err.(*snserrors.SNSError).Details().Key
+			}
+			So(codes, ShouldResemble, []string{
+				CodeMissingKey,
+				CodeMissingKey,
+				CodeInsecureCertURL,
+				CodeMalformedURL,
+				CodeMalformedTimestamp,
+			})
+			So(keys, ShouldResemble, []string{
+				"MessageId",
+				"Token",
+				"SigningCertURL",
+				"SubscribeURL",
+				"Timestamp",
+			})
+		})
+
+		Convey("The MultiError should match each error type with errors.Is", func() {
+			actual := validator.DiagnoseMessage()
+
+			So(errors.Is(actual, ErrMissingKey), ShouldBeTrue)
+			So(errors.Is(actual, ErrInvalidCert), ShouldBeTrue)
+			So(errors.Is(actual, ErrInvalidURL), ShouldBeTrue)
+			So(errors.Is(actual, ErrInvalidTimestamp), ShouldBeTrue)
+			So(errors.Is(actual, ErrIncorrectSignature), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given SNSValidator of message with invalid type", t, func() {
+		validator := newNotificationMessageValidator()
+		validator.MessageMap["Type"] = "Unknown"
+		validator.MessageMap["Message"] = ""
+
+		Convey("It should report both the missing key and the invalid type", func() {
+			actual := validator.DiagnoseMessage()
+
+			So(errors.Is(actual, ErrMissingKey), ShouldBeTrue)
+			So(errors.Is(actual, ErrInvalidType), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given SNSValidator of message with valid structure but untrusted certificate host", t, func() {
+		validator := newNotificationMessageValidator()
+
+		Convey("It should report the certificate failure only", func() {
+			actual := validator.DiagnoseMessage()
+
+			So(actual.(*snserrors.MultiError).Errors(), ShouldHaveLength, 1)
+			So(errors.Is(actual, ErrInvalidCert), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given SNSValidator of message with incorrect signature", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			File("../_assets/fakecert.pem")
+
+		validator := newNotificationMessageValidator()
+		validator.MessageMap["SigningCertURL"] = "https://sns.ap-northeast-1.amazonaws.com/cert.pem"
+
+		Convey("It should verify the signature and report the failure", func() {
+			actual := validator.DiagnoseMessage()
+
+			So(actual.(*snserrors.MultiError).Errors(), ShouldHaveLength, 1)
+			So(errors.Is(actual, ErrIncorrectSignature), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given SNSValidator of message with valid structure and a canceled context", t, func() {
+		validator := New(newValidSignatureMessage())
+		validator.CertFetcher = contextCertFetcher{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		Convey("It should retrieve the certificate with the context", func() {
+			actual := validator.DiagnoseMessageContext(ctx)
+
+			So(errors.Is(actual, ErrInvalidCert), ShouldBeTrue)
+			So(errors.Is(actual, context.Canceled), ShouldBeTrue)
+			So(snserrors.IsTemporary(actual), ShouldBeTrue)
+		})
+	})
+}
+
+// contextCertFetcher fails with the error of the context, if any
+type contextCertFetcher struct{}
+
+func (contextCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("Certificate not found")
+}
+
 func TestBuildSingalbleString(t *testing.T) {
 	Convey("Given a SNSValidator of Notification message", t, func() {
 		validator := SNSValidator{
@@ -841,6 +987,87 @@ Notification
 	})
 }
 
+func TestBuildSignableStringOfFIFOMessage(t *testing.T) {
+	Convey("Given a SNSValidator of Notification message from a FIFO topic", t, func() {
+		validator := SNSValidator{
+			MessageMap: map[string]string{
+				"Type":                   "Notification",
+				"MessageId":              "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+				"Token":                  "",
+				"TopicArn":               "arn:aws:sns:us-west-2:123456789012:MyTopic.fifo",
+				"Message":                "Test notification",
+				"SubscribeURL":           "",
+				"Subject":                "Test subject",
+				"Timestamp":              "2012-04-26T20:45:04.751Z",
+				"SignatureVersion":       "1",
+				"Signature":              "EXAMPLEpH+DcEwjAPg8O9mY8dReBSwksfg2S=",
+				"SigningCertURL":         "https://localhost/cert.pem",
+				"UnsubscribeURL":         "https://localhost/unsubscribe",
+				"SequenceNumber":         "10000000000000000001",
+				"MessageGroupId":         "group-1",
+				"MessageDeduplicationId": "dedup-1",
+			},
+		}
+
+		Convey("It should build and return a signable string with SequenceNumber but without the other FIFO keys", func() {
+			expected := []byte(`Message
+Test notification
+MessageId
+165545c9-2a5c-472c-8df2-7ff2be2b3b1b
+SequenceNumber
+10000000000000000001
+Subject
+Test subject
+Timestamp
+2012-04-26T20:45:04.751Z
+TopicArn
+arn:aws:sns:us-west-2:123456789012:MyTopic.fifo
+Type
+Notification
+`)
+			actual := validator.buildSignableString()
+
+			So(actual, ShouldResemble, expected)
+		})
+	})
+}
+
+func TestBuildSignableStringOfMessageWithEmptySubject(t *testing.T) {
+	Convey("Given a SNSValidator of Notification message with empty but present subject", t, func() {
+		validator := New(mapMessage{
+			"Type":      "Notification",
+			"MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+			"TopicArn":  "arn:aws:sns:us-west-2:123456789012:MyTopic",
+			"Message":   "Test notification",
+			"Subject":   "",
+			"Timestamp": "2012-04-26T20:45:04.751Z",
+		})
+
+		Convey("It should build and return a signable string with the empty subject", func() {
+			expected := []byte(`Message
+Test notification
+MessageId
+165545c9-2a5c-472c-8df2-7ff2be2b3b1b
+Subject
+
+Timestamp
+2012-04-26T20:45:04.751Z
+TopicArn
+arn:aws:sns:us-west-2:123456789012:MyTopic
+Type
+Notification
+`)
+			actual := validator.buildSignableString()
+
+			So(actual, ShouldResemble, expected)
+		})
+
+		Convey("It should not consider the empty subject as present in validation", func() {
+			So(validator.has("Subject"), ShouldBeFalse)
+		})
+	})
+}
+
 func TestGetCertifiateMethod(t *testing.T) {
 	certData := `-----BEGIN CERTIFICATE-----
 MIIC8zCCAlwCCQCLHrKJpPLt9TANBgkqhkiG9w0BAQsFADCBvDELMAkGA1UEBhMC
@@ -885,17 +1112,60 @@ kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
 		}
 
 		Convey("It should return a SNSError", func() {
-			actualData, actualErr := validator.getCertificate()
+			actualData, actualErr := validator.getCertificate(context.Background())
 
 			So(actualData, ShouldBeNil)
 			So(actualErr, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actualErr.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actualErr.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 			Convey("Returned SNSError should be about certificate could not be retrieved", func() {
 				So(actualErr.(*snserrors.SNSError).Error(), ShouldEqual, "Could not retrive the certificate")
 			})
+			Convey("Returned SNSError should have the code and HTTP status code", func() {
+				So(actualErr.(*snserrors.SNSError).Code(), ShouldEqual, CodeCertUnavailable)
+				So(actualErr.(*snserrors.SNSError).Details().StatusCode, ShouldEqual, 404)
+			})
+			Convey("Returned SNSError should be permanent", func() {
+				So(snserrors.IsTemporary(actualErr), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey(`Given SNSValidator of mesage with "SigningCertURL" responding server error`, t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(503)
+
+		validator := newNotificationMessageValidator()
+		validator.MessageMap["SigningCertURL"] = "https://sns.ap-northeast-1.amazonaws.com/cert.pem"
+
+		Convey("It should return a temporary SNSError of type ErrInvalidCert", func() {
+			_, actualErr := validator.getCertificate(context.Background())
+
+			So(actualErr.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
+			So(actualErr.(*snserrors.SNSError).Details().StatusCode, ShouldEqual, 503)
+			So(snserrors.IsTemporary(actualErr), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a cancelled context", t, func() {
+		// gock does not honour the context of the request
+		gock.Off()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		validator := newNotificationMessageValidator()
+		validator.MessageMap["SigningCertURL"] = "https://sns.ap-northeast-1.amazonaws.com/cert.pem"
+
+		Convey("It should return a temporary SNSError wrapping the context error", func() {
+			_, actualErr := validator.getCertificate(ctx)
+
+			So(actualErr.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
+			So(errors.Is(actualErr, context.Canceled), ShouldBeTrue)
+			So(snserrors.IsTemporary(actualErr), ShouldBeTrue)
 		})
 	})
 
@@ -918,13 +1188,16 @@ kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
 		}
 
 		Convey("It should return a SNSError", func() {
-			actualData, actualErr := validator.getCertificate()
+			actualData, actualErr := validator.getCertificate(context.Background())
 
 			So(actualData, ShouldBeNil)
 			So(actualErr, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actualErr.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actualErr.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
+			})
+			Convey("Returned SNSError should be temporary", func() {
+				So(snserrors.IsTemporary(actualErr), ShouldBeTrue)
 			})
 		})
 	})
@@ -953,7 +1226,7 @@ kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
 		}
 
 		Convey("It should fetch the certificate and return certifitcate as slice of byte", func() {
-			actualData, actualErr := validator.getCertificate()
+			actualData, actualErr := validator.getCertificate(context.Background())
 
 			So(actualData, ShouldResemble, []byte(certData))
 			So(actualErr, ShouldBeNil)
@@ -963,7 +1236,7 @@ kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
 		Convey("When the placeholder of the second return value is error-typed", func() {
 			var actual error
 			Convey("It should return an interface value nil", func() {
-				_, actual = validator.getCertificate()
+				_, actual = validator.getCertificate(context.Background())
 
 				So(actual == nil, ShouldBeTrue)
 			})
@@ -971,6 +1244,173 @@ kvCIAouFRHuLo4r9wvF3nUxtWjqfFa6TUfB+xtEalTn3LgKg9mzJ
 	})
 }
 
+func newValidSignatureMessageValidator() SNSValidator {
+	return SNSValidator{
+		MessageMap: map[string]string{
+			"Type":             "Notification",
+			"MessageId":        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+			"Token":            "token-is-unnecessary-in-notification-message",
+			"TopicArn":         "arn:aws:sns:us-west-2:123456789012:MyTopic",
+			"Message":          "Test notification",
+			"SubscribeURL":     "",
+			"Subject":          "Test subject",
+			"Timestamp":        "2012-04-26T20:45:04.751Z",
+			"SignatureVersion": "1",
+			"Signature":        "ol5x/KiU+7dWKRuyD6Y1EntwXo+orXlVgQbq4JDy5uh/+EBBz/mfWQ0X0LXyyxkXXCykDakEz1F0h9y9xV9UitLlYA/tEMzI7WU9ob9d9L8YTCZVaHZUtCu4S0p0eCFzT69q+ijPuH9N1znuZOzDogsJIf8E9/8owtRmi6M50Co=",
+			"SigningCertURL":   "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+			"UnsubscribeURL":   "https://localhost/unsubscribe",
+		},
+	}
+}
+
+func TestDirCertFetcher(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given SNSValidator of message with valid signature and DirCertFetcher", t, func() {
+		validator := newValidSignatureMessageValidator()
+		validator.CertFetcher = DirCertFetcher(dir)
+
+		Convey("It should validate the message with the certificate in the directory", func() {
+			So(validator.ValidateMessage(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given SNSValidator of message with certificate absent from the directory", t, func() {
+		validator := newValidSignatureMessageValidator()
+		validator.MessageMap["SigningCertURL"] = "https://sns.ap-northeast-1.amazonaws.com/absent.pem"
+		validator.CertFetcher = DirCertFetcher(dir)
+
+		Convey("It should return a permanent SNSError of type ErrInvalidCert", func() {
+			actual := validator.ValidateMessage()
+
+			So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
+			So(actual.(*snserrors.SNSError).Code(), ShouldEqual, CodeCertUnavailable)
+			So(actual.(*snserrors.SNSError).Details().Key, ShouldEqual, "SigningCertURL")
+			So(errors.Is(actual, os.ErrNotExist), ShouldBeTrue)
+			So(snserrors.IsTemporary(actual), ShouldBeFalse)
+		})
+	})
+}
+
+func TestValidateAgeMethod(t *testing.T) {
+	Convey("Given SNSValidator of message older than MaxAge", t, func() {
+		validator := newNotificationMessageValidator()
+		validator.MaxAge = time.Hour
+
+		Convey("It should return a SNSError of type ErrInvalidTimestamp", func() {
+			actual := validator.validateAge()
+
+			So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidTimestamp)
+			So(actual.(*snserrors.SNSError).Code(), ShouldEqual, CodeExpiredMessage)
+		})
+
+		Convey("It should fail the validation before verifying the signature", func() {
+			So(errors.Is(validator.ValidateMessage(), ErrInvalidTimestamp), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given SNSValidator of message within MaxAge", t, func() {
+		validator := newNotificationMessageValidator()
+		validator.MessageMap["Timestamp"] = time.Now().UTC().Format(time.RFC3339)
+		validator.MaxAge = time.Hour
+
+		Convey("It should return nil", func() {
+			So(validator.validateAge() == nil, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given SNSValidator without MaxAge", t, func() {
+		validator := newNotificationMessageValidator()
+
+		Convey("It should not check the age", func() {
+			So(validator.validateAge() == nil, ShouldBeTrue)
+		})
+	})
+}
+
+func TestExplainSignatureMethod(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given SNSValidator of message with valid signature", t, func() {
+		validator := newValidSignatureMessageValidator()
+		validator.CertFetcher = DirCertFetcher(dir)
+
+		Convey("It should record every step without failure", func() {
+			actual := validator.ExplainSignature(context.Background())
+			digest := sha1.Sum(validator.buildSignableString())
+
+			So(actual.SignableString, ShouldResemble, validator.buildSignableString())
+			So(actual.SignatureVersion, ShouldEqual, SignatureVersion1)
+			So(actual.Hash, ShouldEqual, crypto.SHA1)
+			So(actual.Digest, ShouldResemble, digest[:])
+			So(actual.SignatureLength, ShouldEqual, 128)
+			So(actual.Certificate.Subject.CommonName, ShouldEqual, "go-sns-message-validator")
+			So(actual.FailedStep, ShouldBeEmpty)
+			So(actual.Err, ShouldBeNil)
+		})
+
+		Convey("When the message is modified", func() {
+			validator.MessageMap["Message"] = "Forged notification"
+
+			Convey("It should fail at checking the signature", func() {
+				actual := validator.ExplainSignature(context.Background())
+
+				So(actual.Certificate, ShouldNotBeNil)
+				So(actual.FailedStep, ShouldEqual, StepCheckSignature)
+				So(errors.Is(actual.Err, rsa.ErrVerification), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the signature is not base64", func() {
+			validator.MessageMap["Signature"] = "not base64"
+
+			Convey("It should fail at decoding the signature", func() {
+				actual := validator.ExplainSignature(context.Background())
+
+				So(actual.SignatureLength, ShouldEqual, -1)
+				So(actual.FailedStep, ShouldEqual, StepDecodeSignature)
+			})
+		})
+
+		Convey("When the certificate is absent", func() {
+			validator.CertFetcher = DirCertFetcher(t.TempDir())
+
+			Convey("It should fail at fetching the certificate and still record the signature length", func() {
+				actual := validator.ExplainSignature(context.Background())
+
+				So(actual.Certificate, ShouldBeNil)
+				So(actual.SignatureLength, ShouldEqual, 128)
+				So(actual.FailedStep, ShouldEqual, StepFetchCert)
+			})
+		})
+
+		Convey("When the certificate host is untrusted", func() {
+			validator.MessageMap["SigningCertURL"] = "https://localhost/cert.pem"
+
+			Convey("It should fail at validating the certificate URL", func() {
+				actual := validator.ExplainSignature(context.Background())
+
+				So(actual.FailedStep, ShouldEqual, StepCertURL)
+			})
+		})
+
+		Convey("When the signature version is unsupported", func() {
+			validator.MessageMap["SignatureVersion"] = "3"
+
+			Convey("It should fail at the signature version without digest", func() {
+				actual := validator.ExplainSignature(context.Background())
+
+				So(actual.Digest, ShouldBeNil)
+				So(actual.FailedStep, ShouldEqual, StepSignatureVersion)
+			})
+		})
+	})
+}
+
 func TestVerifySignature(t *testing.T) {
 	// Mock HTTP request-response
 	certData := `-----BEGIN CERTIFICATE-----
@@ -1043,13 +1483,13 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 		})
 	})
@@ -1073,13 +1513,13 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 			Convey("Returned SNSError should be about insecure HTTP", func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, "The certificate URL is using insecure HTTP scheme")
@@ -1106,17 +1546,25 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 			Convey("Returned SNSError should be reatled to untrusted host", func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, "The certificate URL belongs to an untrusted host")
 			})
+			Convey("Returned SNSError should have the code and details of the untrusted host", func() {
+				So(actual.(*snserrors.SNSError).Code(), ShouldEqual, CodeUntrustedCertHost)
+				So(actual.(*snserrors.SNSError).Details(), ShouldResemble, snserrors.Details{
+					Key:         "SigningCertURL",
+					MessageType: "Notification",
+					CertURL:     "https://localhost/cert.pem",
+				})
+			})
 		})
 	})
 
@@ -1143,13 +1591,13 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 			Convey("Returned SNSError should be about certificate could not be retrieved", func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, "Could not retrive the certificate")
@@ -1181,13 +1629,13 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 		})
 	})
@@ -1216,13 +1664,13 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 		})
 	})
@@ -1251,13 +1699,13 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrInvalidCert", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrInvalidCert)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeInvalidCert)
 			})
 		})
 	})
@@ -1286,13 +1734,13 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrIncorrectSignature", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrIncorrectSignature)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeIncorrectSignature)
 			})
 			Convey("Returned SNSError should be about base64 decode error", func() {
 				So(actual.(*snserrors.SNSError).Error(), ShouldEqual, "Could not base64 decode the signature")
@@ -1324,14 +1772,72 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return a SNSError", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldNotBeNil)
 			So(actual, ShouldHaveSameTypeAs, snserrors.New("Type", "Message"))
 
 			Convey("Returned SNSError should be of type ErrIncorrectSignature", func() {
-				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrIncorrectSignature)
+				So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeIncorrectSignature)
+			})
+			Convey("Returned SNSError should match ErrIncorrectSignature with errors.Is", func() {
+				So(errors.Is(actual, ErrIncorrectSignature), ShouldBeTrue)
+				So(errors.Is(actual, ErrInvalidCert), ShouldBeFalse)
+			})
+			Convey("Returned SNSError should wrap the RSA verification error", func() {
+				So(errors.Is(actual, rsa.ErrVerification), ShouldBeTrue)
 			})
+			Convey("Returned SNSError should be permanent", func() {
+				So(snserrors.IsTemporary(actual), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given SNSValidator of message with unsupported signature version", t, func() {
+		validator := newNotificationMessageValidator()
+		validator.MessageMap["SignatureVersion"] = "3"
+
+		Convey("It should return a SNSError of type ErrIncorrectSignature", func() {
+			actual := validator.verifySignature(context.Background())
+
+			So(actual.(*snserrors.SNSError).Type(), ShouldEqual, ErrTypeIncorrectSignature)
+			So(actual.(*snserrors.SNSError).Code(), ShouldEqual, CodeUnsupportedSignatureVersion)
+			So(actual.(*snserrors.SNSError).Details().Key, ShouldEqual, "SignatureVersion")
+		})
+	})
+
+	Convey("Given SNSValidator accepting signature version 2 only", t, func() {
+		validator := newValidSignatureMessageValidator()
+		validator.SignatureVersions = []string{SignatureVersion2}
+
+		Convey("It should reject the signature version 1", func() {
+			actual := validator.verifySignature(context.Background())
+
+			So(actual.(*snserrors.SNSError).Code(), ShouldEqual, CodeUnsupportedSignatureVersion)
+		})
+	})
+
+	Convey("Given SNSValidator trusting a custom host", t, func() {
+		gock.New("https://localhost").
+			Get("cert.pem").
+			Reply(200).
+			BodyString(certData)
+
+		validator := newNotificationMessageValidator()
+		validator.MessageMap["SigningCertURL"] = "https://localhost/cert.pem"
+		validator.HostPattern = regexp.MustCompile(`^localhost$`)
+
+		Convey("It should trust the custom host", func() {
+			actual := validator.verifySignature(context.Background())
+
+			So(errors.Is(actual, ErrIncorrectSignature), ShouldBeTrue)
+		})
+
+		Convey("It should not trust the AWS hosts", func() {
+			validator.MessageMap["SigningCertURL"] = "https://sns.ap-northeast-1.amazonaws.com/cert.pem"
+			actual := validator.verifySignature(context.Background())
+
+			So(actual.(*snserrors.SNSError).Code(), ShouldEqual, CodeUntrustedCertHost)
 		})
 	})
 
@@ -1359,7 +1865,7 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		}
 
 		Convey("It should return nil", func() {
-			actual := validator.verifySignature()
+			actual := validator.verifySignature(context.Background())
 
 			So(actual, ShouldBeNil)
 		})
@@ -1368,11 +1874,43 @@ MloYDzIwNjcwOTA1MTcwMDUyWjCBvDELMAkGA1UEBhMCSEsxEjAQBgNVBAgMCUhv
 		Convey("When the placeholder of thereturn value is error-typed", func() {
 			var actual error
 			Convey("It should return an interface value nil", func() {
-				actual = validator.verifySignature()
+				actual = validator.verifySignature(context.Background())
 
 				So(actual == nil, ShouldBeTrue)
 			})
 		})
 	})
 
+	Convey("Given SNSValidator of FIFO message with valid signature", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			BodyString(certData)
+
+		validator := SNSValidator{
+			MessageMap: map[string]string{
+				"Type":                   "Notification",
+				"MessageId":              "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+				"Token":                  "",
+				"TopicArn":               "arn:aws:sns:us-west-2:123456789012:MyTopic.fifo",
+				"Message":                "Test notification",
+				"SubscribeURL":           "",
+				"Subject":                "Test subject",
+				"Timestamp":              "2012-04-26T20:45:04.751Z",
+				"SignatureVersion":       "1",
+				"Signature":              "HEtmpdf2egXQZGU+UviJ0ut92nN/0qvrztMo04suXvFuYzkrmvy1V7NVG/nZqHF8NrPOFTssV08JxEFNE5QgRwMzuxlz5vIXECsn7McvMx6X+J9djenVDI5GJgkVyUAqOmrcQgVBIieFY4kEe6gNhcJMJdZrxsTPjavH9GBnqaU=",
+				"SigningCertURL":         "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+				"UnsubscribeURL":         "https://localhost/unsubscribe",
+				"SequenceNumber":         "10000000000000000001",
+				"MessageGroupId":         "group-1",
+				"MessageDeduplicationId": "dedup-1",
+			},
+		}
+
+		Convey("It should return nil", func() {
+			actual := validator.verifySignature(context.Background())
+
+			So(actual, ShouldBeNil)
+		})
+	})
 }
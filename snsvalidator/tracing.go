@@ -0,0 +1,121 @@
+package snsvalidator
+
+import (
+	"context"
+)
+
+// Names of the spans of the validation steps
+const (
+	// SpanValidate spans the whole validation of a message
+	SpanValidate = "sns.validate"
+	// SpanValidateStructure spans the validation of the message structure
+	SpanValidateStructure = "sns.validate_structure"
+	// SpanVerifySignature spans the verification of the signature, including
+	// the resolution of the signing certificate
+	SpanVerifySignature = "sns.verify_signature"
+	// SpanResolveCertificate spans the resolution of the signing certificate,
+	// from the certificate cache of a Verifier or the network
+	SpanResolveCertificate = "sns.resolve_certificate"
+	// SpanFetchCertificate spans the retrieval of the signing certificate by
+	// the CertFetcher of a Verifier on a certificate cache miss
+	SpanFetchCertificate = "sns.fetch_certificate"
+)
+
+// Keys of the span attributes
+const (
+	AttrMessageId   = "sns.message_id"
+	AttrTopicArn    = "sns.topic_arn"
+	AttrMessageType = "sns.message_type"
+	AttrCertURL     = "sns.cert_url"
+	// AttrCertCache is whether the signing certificate is found in the
+	// certificate cache of a Verifier, CertCacheHit or CertCacheMiss
+	AttrCertCache = "sns.cert_cache"
+	// AttrSignatureVersion is the "SignatureVersion" of the message
+	AttrSignatureVersion = "sns.signature_version"
+	// AttrVerificationCache is whether the signature is found in the
+	// verification cache of a Verifier, VerificationCacheHit or
+	// VerificationCacheMiss. It is set only if the cache is enabled with
+	// WithVerificationCache
+	AttrVerificationCache = "sns.verification_cache"
+)
+
+// Values of AttrCertCache
+const (
+	CertCacheHit  = "hit"
+	CertCacheMiss = "miss"
+)
+
+// Values of AttrVerificationCache
+const (
+	VerificationCacheHit  = "hit"
+	VerificationCacheMiss = "miss"
+)
+
+// Tracer starts the spans of the validation steps, e.g. to trace the
+// validation with OpenTelemetry. It must be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span of the name as a child of the span in the context,
+	// if any, and returns the context of the span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetAttribute sets the attribute of the span
+	SetAttribute(key string, value string)
+	// End ends the span with the error of the step, nil on success
+	End(err error)
+}
+
+// WithTracer sets the Tracer of the validations, see SNSValidator.Tracer
+func WithTracer(tracer Tracer) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.tracer = tracer
+	}
+}
+
+// spanContextKey is the key of the current Span in the context
+type spanContextKey struct{}
+
+// nopSpan is the Span of the validation steps without Tracer
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(key string, value string) {}
+
+func (nopSpan) End(err error) {}
+
+// startSpan starts the span of the validation step with the Tracer, or
+// returns a no-op span if the Tracer is not set
+func startSpan(ctx context.Context, tracer Tracer, name string) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, nopSpan{}
+	}
+
+	ctx, span := tracer.Start(ctx, name)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// spanFromContext returns the current Span of the validation steps in the
+// context, or a no-op span if there is none
+func spanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanContextKey{}).(Span); ok {
+		return span
+	}
+	return nopSpan{}
+}
+
+// tracingCertFetcher spans the retrievals of the signing certificates by the
+// underlying CertFetcher
+type tracingCertFetcher struct {
+	fetcher CertFetcher
+	tracer  Tracer
+}
+
+func (tracing tracingCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	ctx, span := startSpan(ctx, tracing.tracer, SpanFetchCertificate)
+	span.SetAttribute(AttrCertURL, certURL)
+
+	certData, err := tracing.fetcher.FetchCertificate(ctx, certURL)
+	span.End(err)
+	return certData, err
+}
@@ -0,0 +1,140 @@
+package snsvalidator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordedSpan is a span recorded by recordingTracer
+type recordedSpan struct {
+	tracer     *recordingTracer
+	name       string
+	parent     string
+	attributes map[string]string
+	ended      bool
+	err        error
+}
+
+func (span *recordedSpan) SetAttribute(key string, value string) {
+	span.tracer.mu.Lock()
+	defer span.tracer.mu.Unlock()
+	span.attributes[key] = value
+}
+
+func (span *recordedSpan) End(err error) {
+	span.tracer.mu.Lock()
+	defer span.tracer.mu.Unlock()
+	span.ended = true
+	span.err = err
+}
+
+// recordingTracer records the spans started
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpanKey struct{}
+
+func (tracer *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	span := &recordedSpan{tracer: tracer, name: name, attributes: map[string]string{}}
+	if parent, ok := ctx.Value(recordedSpanKey{}).(*recordedSpan); ok {
+		span.parent = parent.name
+	}
+	tracer.spans = append(tracer.spans, span)
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// span returns the last span of the name
+func (tracer *recordingTracer) span(name string) *recordedSpan {
+	for i := len(tracer.spans) - 1; i >= 0; i-- {
+		if tracer.spans[i].name == name {
+			return tracer.spans[i]
+		}
+	}
+	return nil
+}
+
+func TestWithTracer(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier with a Tracer", t, func() {
+		tracer := &recordingTracer{}
+		verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithTracer(tracer))
+		message := newValidSignatureMessage()
+
+		Convey("When a message is verified", func() {
+			So(verifier.Verify(context.Background(), message), ShouldBeNil)
+
+			Convey("It should span the validation steps", func() {
+				names := []string{}
+				for _, span := range tracer.spans {
+					names = append(names, span.name)
+					So(span.ended, ShouldBeTrue)
+					So(span.err, ShouldBeNil)
+				}
+
+				So(names, ShouldResemble, []string{
+					SpanValidate,
+					SpanValidateStructure,
+					SpanVerifySignature,
+					SpanResolveCertificate,
+					SpanFetchCertificate,
+				})
+				So(tracer.span(SpanValidateStructure).parent, ShouldEqual, SpanValidate)
+				So(tracer.span(SpanResolveCertificate).parent, ShouldEqual, SpanVerifySignature)
+				So(tracer.span(SpanFetchCertificate).parent, ShouldEqual, SpanResolveCertificate)
+			})
+
+			Convey("It should set the attributes of the message", func() {
+				So(tracer.span(SpanValidate).attributes, ShouldResemble, map[string]string{
+					AttrMessageId:   message["MessageId"],
+					AttrTopicArn:    message["TopicArn"],
+					AttrMessageType: message["Type"],
+				})
+				So(tracer.span(SpanVerifySignature).attributes[AttrSignatureVersion], ShouldEqual, "1")
+				So(tracer.span(SpanResolveCertificate).attributes[AttrCertCache], ShouldEqual, CertCacheMiss)
+			})
+
+			Convey("When the message is verified again", func() {
+				tracer.spans = nil
+				So(verifier.Verify(context.Background(), message), ShouldBeNil)
+
+				Convey("It should resolve the certificate from the cache without fetching", func() {
+					So(tracer.span(SpanResolveCertificate).attributes[AttrCertCache], ShouldEqual, CertCacheHit)
+					So(tracer.span(SpanFetchCertificate), ShouldBeNil)
+				})
+			})
+		})
+
+		Convey("When a forged message is verified", func() {
+			message["Message"] = "Forged notification"
+			err := verifier.Verify(context.Background(), message)
+
+			Convey("It should end the spans of the failed steps with the error", func() {
+				So(tracer.span(SpanVerifySignature).err, ShouldEqual, err)
+				So(tracer.span(SpanValidate).err, ShouldEqual, err)
+				So(tracer.span(SpanResolveCertificate).err, ShouldBeNil)
+			})
+		})
+
+		Convey("When a malformed message is verified", func() {
+			err := verifier.Verify(context.Background(), mapMessage{"Type": "Notification"})
+
+			Convey("It should not span the signature verification", func() {
+				So(tracer.span(SpanValidateStructure).err, ShouldEqual, err)
+				So(tracer.span(SpanVerifySignature), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package snsvalidator
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"sync"
+)
+
+// WithVerificationCache caches up to size successful signature verifications,
+// so that the messages delivered again, e.g. by the SNS retries, are not
+// verified with RSA again. A verification is identified by the fingerprint of
+// the signing certificate, the signature and the digest of the signable
+// string, so a cached verification matches only the same signed data. The
+// least recently used verification is evicted first. A non-positive size
+// disables the cache, which is the default
+func WithVerificationCache(size int) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.verificationCacheSize = size
+	}
+}
+
+// verificationKey identifies a successful signature verification
+type verificationKey struct {
+	// cert is the SHA256 fingerprint of the signing certificate
+	cert [sha256.Size]byte
+	// signature is the SHA256 hash of the decoded signature
+	signature [sha256.Size]byte
+	// hash is the hash function of the digest
+	hash crypto.Hash
+	// digest is the digest of the signable string, padded with zeros
+	digest [sha256.Size]byte
+}
+
+// newVerificationKey returns the verificationKey of verifying the signature
+// of the digest with the certificate
+func newVerificationKey(cert *x509.Certificate, hash crypto.Hash, digest []byte, signature []byte) verificationKey {
+	key := verificationKey{
+		cert:      sha256.Sum256(cert.Raw),
+		signature: sha256.Sum256(signature),
+		hash:      hash,
+	}
+	copy(key.digest[:], digest)
+	return key
+}
+
+// verificationCache is a bounded cache of the successful signature
+// verifications, evicting the least recently used one
+type verificationCache struct {
+	mu      sync.Mutex
+	entries *lruCache[verificationKey, struct{}]
+}
+
+func newVerificationCache(size int) *verificationCache {
+	return &verificationCache{entries: newLRUCache[verificationKey, struct{}](size)}
+}
+
+// contains returns whether the verification is cached, and marks it as the
+// most recently used if so
+func (cache *verificationCache) contains(key verificationKey) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	_, exists := cache.entries.get(key)
+	return exists
+}
+
+// add caches the verification, evicting the least recently used one if the
+// cache is full
+func (cache *verificationCache) add(key verificationKey) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries.add(key, struct{}{})
+}
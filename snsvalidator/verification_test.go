@@ -0,0 +1,101 @@
+package snsvalidator
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithVerificationCache(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier with a verification cache", t, func() {
+		tracer := &recordingTracer{}
+		verifier := NewVerifier(
+			WithCertFetcher(DirCertFetcher(dir)),
+			WithTracer(tracer),
+			WithVerificationCache(10),
+		)
+		message := newValidSignatureMessage()
+
+		Convey("When a message is verified", func() {
+			So(verifier.Verify(context.Background(), message), ShouldBeNil)
+
+			Convey("It should verify the signature", func() {
+				So(tracer.span(SpanVerifySignature).attributes[AttrVerificationCache], ShouldEqual, VerificationCacheMiss)
+			})
+
+			Convey("It should short-circuit the verification of the same message delivered again", func() {
+				So(verifier.Verify(context.Background(), newValidSignatureMessage()), ShouldBeNil)
+
+				So(tracer.span(SpanVerifySignature).attributes[AttrVerificationCache], ShouldEqual, VerificationCacheHit)
+			})
+
+			Convey("It should still reject the message modified", func() {
+				message["Message"] = "Forged notification"
+				err := verifier.Verify(context.Background(), message)
+
+				So(errors.Is(err, ErrIncorrectSignature), ShouldBeTrue)
+				So(tracer.span(SpanVerifySignature).attributes[AttrVerificationCache], ShouldEqual, VerificationCacheMiss)
+			})
+		})
+
+		Convey("When a forged message is rejected", func() {
+			message["Message"] = "Forged notification"
+			verifier.Verify(context.Background(), message)
+
+			Convey("It should not cache the failure", func() {
+				So(errors.Is(verifier.Verify(context.Background(), message), ErrIncorrectSignature), ShouldBeTrue)
+				So(tracer.span(SpanVerifySignature).attributes[AttrVerificationCache], ShouldEqual, VerificationCacheMiss)
+			})
+		})
+	})
+
+	Convey("Given a Verifier without verification cache", t, func() {
+		tracer := &recordingTracer{}
+		verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithTracer(tracer))
+
+		Convey("It should always verify the signature", func() {
+			So(verifier.Verify(context.Background(), newValidSignatureMessage()), ShouldBeNil)
+
+			_, exists := tracer.span(SpanVerifySignature).attributes[AttrVerificationCache]
+			So(exists, ShouldBeFalse)
+		})
+	})
+}
+
+func TestVerificationCache(t *testing.T) {
+	Convey("Given a full verification cache", t, func() {
+		cache := newVerificationCache(2)
+		keys := []verificationKey{
+			{hash: crypto.SHA1, digest: [32]byte{1}},
+			{hash: crypto.SHA1, digest: [32]byte{2}},
+			{hash: crypto.SHA1, digest: [32]byte{3}},
+		}
+		cache.add(keys[0])
+		cache.add(keys[1])
+
+		Convey("It should evict the least recently used verification", func() {
+			So(cache.contains(keys[0]), ShouldBeTrue)
+			cache.add(keys[2])
+
+			So(cache.contains(keys[0]), ShouldBeTrue)
+			So(cache.contains(keys[1]), ShouldBeFalse)
+			So(cache.contains(keys[2]), ShouldBeTrue)
+			So(cache.entries.len(), ShouldEqual, 2)
+		})
+
+		Convey("It should not grow with a verification added again", func() {
+			cache.add(keys[1])
+
+			So(cache.entries.len(), ShouldEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,298 @@
+package snsvalidator
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultCertCacheTTL is the default duration a Verifier caches a retrieved
+// signing certificate for
+const DefaultCertCacheTTL = time.Hour
+
+// DefaultCertCacheSize is the default number of signing certificates a
+// Verifier caches
+const DefaultCertCacheSize = 100
+
+// Verifier verifies SNS messages with a configuration shared across the
+// messages, such as the trusted hosts and the policies. Unlike SNSValidator,
+// which is built for a single message, a Verifier is built once and is safe
+// for concurrent use by multiple goroutines.
+type Verifier struct {
+	httpClient        *http.Client
+	hostPattern       *regexp.Regexp
+	certFetcher       CertFetcher
+	certCacheTTL      time.Duration
+	certCacheSize     int
+	maxAge            time.Duration
+	signatureVersions []string
+	batchWorkers      int
+	observer          Observer
+	logger            *slog.Logger
+	tracer            Tracer
+
+	verificationCacheSize int
+	verifications         *verificationCache
+}
+
+// VerifierOption configures a Verifier
+type VerifierOption func(*Verifier)
+
+// WithHTTPClient sets the HTTP client retrieving the signing certificates. It
+// is ignored if a CertFetcher is set with WithCertFetcher
+func WithHTTPClient(client *http.Client) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.httpClient = client
+	}
+}
+
+// WithHostPattern sets the pattern of the hostnames of the trusted signing
+// certificate URLs, instead of the AWS SNS hosts
+func WithHostPattern(hostPattern *regexp.Regexp) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.hostPattern = hostPattern
+	}
+}
+
+// WithCertFetcher sets the CertFetcher retrieving the signing certificates
+func WithCertFetcher(fetcher CertFetcher) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.certFetcher = fetcher
+	}
+}
+
+// WithCertCache sets the duration a retrieved signing certificate is cached
+// for. A non-positive duration disables the cache
+func WithCertCache(ttl time.Duration) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.certCacheTTL = ttl
+	}
+}
+
+// WithCertCacheSize sets the number of signing certificates cached,
+// DefaultCertCacheSize by default. The least recently used certificate is
+// evicted first. A non-positive size sets the default
+func WithCertCacheSize(size int) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.certCacheSize = size
+	}
+}
+
+// WithMaxAge sets the maximum age of the messages by their "Timestamp"
+func WithMaxAge(maxAge time.Duration) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.maxAge = maxAge
+	}
+}
+
+// WithSignatureVersions sets the accepted signature versions
+func WithSignatureVersions(signatureVersions ...string) VerifierOption {
+	return func(verifier *Verifier) {
+		verifier.signatureVersions = signatureVersions
+	}
+}
+
+// NewVerifier returns a new Verifier configured with the options. By default,
+// it trusts the AWS SNS hosts, retrieves the signing certificates with
+// http.DefaultClient and caches them for DefaultCertCacheTTL.
+func NewVerifier(options ...VerifierOption) *Verifier {
+	verifier := &Verifier{certCacheTTL: DefaultCertCacheTTL}
+	for _, option := range options {
+		option(verifier)
+	}
+
+	if verifier.certFetcher == nil {
+		verifier.certFetcher = HTTPCertFetcher{Client: verifier.httpClient}
+	}
+	if verifier.observer == nil {
+		verifier.observer = NopObserver{}
+	} else {
+		verifier.certFetcher = observingCertFetcher{
+			fetcher:  verifier.certFetcher,
+			observer: verifier.observer,
+		}
+	}
+	if verifier.tracer != nil {
+		verifier.certFetcher = tracingCertFetcher{
+			fetcher: verifier.certFetcher,
+			tracer:  verifier.tracer,
+		}
+	}
+	if verifier.verificationCacheSize > 0 {
+		verifier.verifications = newVerificationCache(verifier.verificationCacheSize)
+	}
+	if verifier.certCacheTTL > 0 {
+		verifier.certFetcher = newCachingCertFetcher(verifier.certFetcher, verifier.certCacheTTL, verifier.certCacheSize, verifier.observer)
+	}
+	return verifier
+}
+
+// Validator returns the SNSValidator of the message configured with the
+// Verifier
+func (verifier *Verifier) Validator(message Message) *SNSValidator {
+	validator := New(message)
+	validator.HostPattern = verifier.hostPattern
+	validator.CertFetcher = verifier.certFetcher
+	validator.MaxAge = verifier.maxAge
+	validator.SignatureVersions = verifier.signatureVersions
+	validator.Logger = verifier.logger
+	validator.Tracer = verifier.tracer
+	validator.verifications = verifier.verifications
+	return validator
+}
+
+// Verify validates the message like SNSValidator.ValidateMessageContext
+// does, with the configuration of the Verifier
+func (verifier *Verifier) Verify(ctx context.Context, message Message) error {
+	return verifier.validate(ctx, verifier.Validator(message))
+}
+
+// certCacheEntry records a cached signing certificate
+type certCacheEntry struct {
+	certData []byte
+	// cert is the parsed certData, nil until it is parsed successfully
+	cert *x509.Certificate
+	// trustedBy is the host pattern which trusted the certificate URL, nil
+	// until the URL is validated
+	trustedBy *regexp.Regexp
+	expires   time.Time
+}
+
+// cachingCertFetcher caches the signing certificates retrieved by the
+// underlying CertFetcher by their URL, up to a number of certificates evicting
+// the least recently used one. Failures are not cached. Concurrent retrievals
+// of the same URL are shared.
+type cachingCertFetcher struct {
+	fetcher  CertFetcher
+	ttl      time.Duration
+	observer Observer
+
+	mu      sync.Mutex
+	entries *lruCache[string, certCacheEntry]
+	calls   map[string]*certCall
+}
+
+func newCachingCertFetcher(fetcher CertFetcher, ttl time.Duration, size int, observer Observer) *cachingCertFetcher {
+	if size <= 0 {
+		size = DefaultCertCacheSize
+	}
+	return &cachingCertFetcher{
+		fetcher:  fetcher,
+		ttl:      ttl,
+		observer: observer,
+		entries:  newLRUCache[string, certCacheEntry](size),
+		calls:    make(map[string]*certCall),
+	}
+}
+
+func (cache *cachingCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	cache.mu.Lock()
+	entry, exists := cache.entries.get(certURL)
+	hit := exists && time.Now().Before(entry.expires)
+	if exists && !hit {
+		cache.entries.remove(certURL)
+	}
+	var call *certCall
+	inFlight := false
+	if !hit {
+		call, inFlight = cache.calls[certURL]
+		if !inFlight {
+			call = &certCall{done: make(chan struct{})}
+			cache.calls[certURL] = call
+		}
+	}
+	cache.mu.Unlock()
+
+	cache.observer.CertCacheLookup(ctx, certURL, hit)
+	if hit {
+		spanFromContext(ctx).SetAttribute(AttrCertCache, CertCacheHit)
+		return entry.certData, nil
+	}
+	spanFromContext(ctx).SetAttribute(AttrCertCache, CertCacheMiss)
+
+	if !inFlight {
+		// The retrieval is shared, so it must not be canceled with the context
+		// of the caller starting it. Each caller stops waiting on its own
+		// context instead
+		go cache.fetch(context.WithoutCancel(ctx), certURL, call)
+	}
+
+	select {
+	case <-call.done:
+		return call.certData, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch retrieves the certificate of the shared call with the underlying
+// CertFetcher and caches it on success. The call is completed even if the
+// CertFetcher panics, so that the callers waiting for it are not stuck.
+func (cache *cachingCertFetcher) fetch(ctx context.Context, certURL string, call *certCall) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.certData, call.err = nil, fmt.Errorf("Certificate retrieval panicked: %v", r)
+		}
+
+		cache.mu.Lock()
+		delete(cache.calls, certURL)
+		if call.err == nil {
+			cache.entries.add(certURL, certCacheEntry{certData: call.certData, expires: time.Now().Add(cache.ttl)})
+		}
+		cache.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.certData, call.err = cache.fetcher.FetchCertificate(ctx, certURL)
+}
+
+func (cache *cachingCertFetcher) trustsCertURL(certURL string, hostPattern *regexp.Regexp) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, exists := cache.entries.get(certURL)
+	return exists && entry.trustedBy == hostPattern
+}
+
+func (cache *cachingCertFetcher) trustCertURL(certURL string, hostPattern *regexp.Regexp) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if entry, exists := cache.entries.get(certURL); exists {
+		entry.trustedBy = hostPattern
+		cache.entries.add(certURL, entry)
+	}
+}
+
+// parseCertificate returns the parsed certificate of the cache entry of the
+// URL if it is the certificate data, and parses and caches it otherwise.
+// Failures are not cached.
+func (cache *cachingCertFetcher) parseCertificate(certURL string, certData []byte) (*x509.Certificate, error) {
+	cache.mu.Lock()
+	entry, exists := cache.entries.get(certURL)
+	cache.mu.Unlock()
+
+	if exists && entry.cert != nil && bytes.Equal(entry.certData, certData) {
+		return entry.cert, nil
+	}
+
+	cert, err := parseCertificate(certData)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	// The entry may have been replaced with another certificate meanwhile
+	if entry, exists := cache.entries.get(certURL); exists && bytes.Equal(entry.certData, certData) {
+		entry.cert = cert
+		cache.entries.add(certURL, entry)
+	}
+	cache.mu.Unlock()
+	return cert, nil
+}
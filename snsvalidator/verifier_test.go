@@ -0,0 +1,298 @@
+package snsvalidator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/h2non/gock.v1"
+
+	"github.com/yuhlau/go-sns-message-validator/snserrors"
+)
+
+// mapMessage is a Message of a message map, where every key in the map is
+// present
+type mapMessage map[string]string
+
+func (message mapMessage) Field(key string) (string, bool) {
+	value, present := message[key]
+	return value, present
+}
+
+// newValidSignatureMessage returns the Message of
+// newValidSignatureMessageValidator without the empty keys
+func newValidSignatureMessage() mapMessage {
+	message := mapMessage{}
+	for key, value := range newValidSignatureMessageValidator().MessageMap {
+		if value != "" {
+			message[key] = value
+		}
+	}
+	return message
+}
+
+// countingCertFetcher counts the certificates retrieved by the underlying
+// CertFetcher
+type countingCertFetcher struct {
+	CertFetcher
+
+	mu    sync.Mutex
+	count int
+}
+
+func (fetcher *countingCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	fetcher.mu.Lock()
+	fetcher.count++
+	fetcher.mu.Unlock()
+	return fetcher.CertFetcher.FetchCertificate(ctx, certURL)
+}
+
+func TestNewVerifier(t *testing.T) {
+	Convey("Given a Verifier with the default options", t, func() {
+		verifier := NewVerifier()
+
+		Convey("It should cache the certificates retrieved with HTTP", func() {
+			So(verifier.certFetcher, ShouldHaveSameTypeAs, &cachingCertFetcher{})
+			So(verifier.certFetcher.(*cachingCertFetcher).fetcher, ShouldResemble, HTTPCertFetcher{})
+			So(verifier.certFetcher.(*cachingCertFetcher).ttl, ShouldEqual, DefaultCertCacheTTL)
+			So(verifier.certFetcher.(*cachingCertFetcher).entries.size, ShouldEqual, DefaultCertCacheSize)
+		})
+	})
+
+	Convey("Given a Verifier without certificate cache", t, func() {
+		fetcher := DirCertFetcher("certs")
+		verifier := NewVerifier(WithCertFetcher(fetcher), WithCertCache(0))
+
+		Convey("It should use the CertFetcher directly", func() {
+			So(verifier.certFetcher, ShouldEqual, fetcher)
+		})
+	})
+}
+
+// slowCertFetcher returns the certificate data for any URL after a while
+type slowCertFetcher []byte
+
+func (certData slowCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	time.Sleep(20 * time.Millisecond)
+	return certData, nil
+}
+
+// gatedCertFetcher returns the certificate data for any URL once released,
+// signaling each retrieval started
+type gatedCertFetcher struct {
+	certData []byte
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (fetcher *gatedCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	fetcher.started <- struct{}{}
+	<-fetcher.release
+	return fetcher.certData, ctx.Err()
+}
+
+// panickingCertFetcher panics on any retrieval
+type panickingCertFetcher struct{}
+
+func (panickingCertFetcher) FetchCertificate(ctx context.Context, certURL string) ([]byte, error) {
+	panic("Certificate fetcher is broken")
+}
+
+func TestCachingCertFetcher(t *testing.T) {
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	ctx := context.Background()
+
+	Convey("Given a cachingCertFetcher", t, func() {
+		fetcher := &countingCertFetcher{CertFetcher: staticCertFetcher(certData)}
+		cache := newCachingCertFetcher(fetcher, time.Hour, 2, NopObserver{})
+
+		Convey("It should delete an expired certificate on lookup", func() {
+			cache.ttl = -time.Second
+			cache.FetchCertificate(ctx, "https://example.com/cert.pem")
+			So(cache.entries.len(), ShouldEqual, 1)
+
+			fetcher.CertFetcher = DirCertFetcher(t.TempDir())
+			_, err := cache.FetchCertificate(ctx, "https://example.com/cert.pem")
+
+			So(err, ShouldNotBeNil)
+			So(fetcher.count, ShouldEqual, 2)
+			So(cache.entries.len(), ShouldEqual, 0)
+		})
+
+		Convey("It should evict the least recently used certificate beyond its size", func() {
+			for _, certURL := range []string{"https://example.com/1.pem", "https://example.com/2.pem", "https://example.com/3.pem"} {
+				cache.FetchCertificate(ctx, certURL)
+			}
+
+			So(cache.entries.len(), ShouldEqual, 2)
+			_, exists := cache.entries.get("https://example.com/1.pem")
+			So(exists, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a cachingCertFetcher with a slow CertFetcher", t, func() {
+		fetcher := &countingCertFetcher{CertFetcher: slowCertFetcher(certData)}
+		cache := newCachingCertFetcher(fetcher, time.Hour, 0, NopObserver{})
+
+		Convey("It should share a retrieval across concurrent misses", func() {
+			var wg sync.WaitGroup
+			results := make([][]byte, 10)
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i], _ = cache.FetchCertificate(ctx, "https://example.com/cert.pem")
+				}(i)
+			}
+			wg.Wait()
+
+			So(fetcher.count, ShouldEqual, 1)
+			for _, result := range results {
+				So(result, ShouldResemble, certData)
+			}
+			So(cache.calls, ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given a cachingCertFetcher retrieving a certificate for a caller", t, func() {
+		fetcher := &gatedCertFetcher{certData: certData, started: make(chan struct{}, 2), release: make(chan struct{})}
+		cache := newCachingCertFetcher(fetcher, time.Hour, 0, NopObserver{})
+
+		first, cancel := context.WithCancel(ctx)
+		firstErr := make(chan error)
+		go func() {
+			_, err := cache.FetchCertificate(first, "https://example.com/cert.pem")
+			firstErr <- err
+		}()
+		<-fetcher.started
+
+		Convey("It should not fail the other callers when the caller is canceled", func() {
+			type result struct {
+				certData []byte
+				err      error
+			}
+			second := make(chan result)
+			go func() {
+				certData, err := cache.FetchCertificate(ctx, "https://example.com/cert.pem")
+				second <- result{certData, err}
+			}()
+
+			cancel()
+			So(<-firstErr, ShouldEqual, context.Canceled)
+
+			close(fetcher.release)
+			actual := <-second
+			So(actual.err, ShouldBeNil)
+			So(actual.certData, ShouldResemble, certData)
+		})
+	})
+
+	Convey("Given a cachingCertFetcher with a panicking CertFetcher", t, func() {
+		cache := newCachingCertFetcher(panickingCertFetcher{}, time.Hour, 0, NopObserver{})
+
+		Convey("It should fail the retrieval and not block the next ones", func() {
+			_, err := cache.FetchCertificate(ctx, "https://example.com/cert.pem")
+			So(err, ShouldNotBeNil)
+			So(cache.calls, ShouldBeEmpty)
+
+			_, err = cache.FetchCertificate(ctx, "https://example.com/cert.pem")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestVerifyMethod(t *testing.T) {
+	dir := t.TempDir()
+	certData, _ := os.ReadFile("../_assets/fakecert.pem")
+	os.WriteFile(filepath.Join(dir, "cert.pem"), certData, 0644)
+
+	Convey("Given a Verifier caching the certificates", t, func() {
+		fetcher := &countingCertFetcher{CertFetcher: DirCertFetcher(dir)}
+		verifier := NewVerifier(WithCertFetcher(fetcher))
+		message := newValidSignatureMessage()
+
+		Convey("It should retrieve the certificate once across concurrent verifications", func() {
+			So(verifier.Verify(context.Background(), message), ShouldBeNil)
+
+			var wg sync.WaitGroup
+			errs := make([]error, 10)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = verifier.Verify(context.Background(), message)
+				}(i)
+			}
+			wg.Wait()
+
+			So(errs, ShouldResemble, make([]error, 10))
+			So(fetcher.count, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a Verifier caching the certificates and the verifications", t, func() {
+		verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithVerificationCache(10))
+		message := newBenchmarkMessage()
+		So(verifier.Verify(context.Background(), message), ShouldBeNil)
+		So(verifier.Verify(context.Background(), message), ShouldBeNil)
+
+		Convey("It should verify a message verified before without allocating", func() {
+			allocs := testing.AllocsPerRun(100, func() {
+				verifier.Verify(context.Background(), message)
+			})
+
+			So(allocs, ShouldEqual, 0)
+		})
+
+		Convey("It should validate the trusted certificate URL again with another host pattern", func() {
+			validator := verifier.Validator(message)
+			validator.HostPattern = regexp.MustCompile(`^example\.com$`)
+
+			So(errors.Is(validator.ValidateMessage(), ErrInvalidCert), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a Verifier with the HTTP client", t, func() {
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			File("../_assets/fakecert.pem")
+
+		verifier := NewVerifier()
+		message := newValidSignatureMessage()
+
+		Convey("It should verify the message with the retrieved certificate", func() {
+			So(verifier.Verify(context.Background(), message), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a Verifier with policies", t, func() {
+		message := newValidSignatureMessage()
+
+		Convey("It should apply the max age", func() {
+			verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithMaxAge(time.Hour))
+
+			So(errors.Is(verifier.Verify(context.Background(), message), ErrInvalidTimestamp), ShouldBeTrue)
+		})
+
+		Convey("It should apply the signature versions", func() {
+			verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithSignatureVersions(SignatureVersion2))
+			err := verifier.Verify(context.Background(), message)
+
+			So(err.(*snserrors.SNSError).Code(), ShouldEqual, CodeUnsupportedSignatureVersion)
+		})
+
+		Convey("It should apply the host pattern", func() {
+			verifier := NewVerifier(WithCertFetcher(DirCertFetcher(dir)), WithHostPattern(regexp.MustCompile(`^localhost$`)))
+			err := verifier.Verify(context.Background(), message)
+
+			So(err.(*snserrors.SNSError).Code(), ShouldEqual, CodeUntrustedCertHost)
+		})
+	})
+}
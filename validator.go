@@ -0,0 +1,34 @@
+package validator
+
+import (
+	"context"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// defaultVerifier is the Verifier of Validate, shared to cache the signing
+// certificates across the calls
+var defaultVerifier = snsvalidator.NewVerifier()
+
+// Validate decodes the JSON-encoded SNS message, such as a HTTP request body,
+// and validates it in one call. The SNS message is validated with a Verifier
+// trusting the AWS SNS hosts and caching the signing certificates, use
+// snsvalidator.NewVerifier and SNSMessage.VerifyWith to configure the
+// validation.
+// The returned SNSMessage is marked as validated, so that its payload can be
+// decoded by snsmessage.DecodeValidatedMessage.
+// If the JSON is malformed, it returns SNSError of type
+// snsmessage.ErrMalformedJSON
+// If the SNS message is invalid, it returns the SNSError of the validation
+func Validate(ctx context.Context, body []byte) (*snsmessage.SNSMessage, error) {
+	message, err := snsmessage.NewFromJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := message.VerifyWith(ctx, defaultVerifier); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
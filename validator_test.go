@@ -0,0 +1,63 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/h2non/gock.v1"
+
+	"github.com/yuhlau/go-sns-message-validator/snsmessage"
+	"github.com/yuhlau/go-sns-message-validator/snsvalidator"
+)
+
+// Notification signed by _assets/fakecert.key
+const notificationBody = `{
+  "Type": "Notification",
+  "MessageId": "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+  "TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic",
+  "Message": "{\"id\":1,\"name\":\"test\"}",
+  "Subject": "Test subject",
+  "Timestamp": "2012-04-26T20:45:04.751Z",
+  "SignatureVersion": "1",
+  "Signature": "Z2ZxqGoxh1zOankzqfvCMZlSHaWriMB8SlH36camvWEBpLvha2P5Y3nm1pCWW+OvomleeFeME6LMsCaysV5R8eESfmLvxQ5U5ETNVOSheEVfzVWxUTV6nSrgiq0OomOMyKGE2FbFGyhuARAvZSMKGLjvQraRqJ/Pb/y6wIYeLbU=",
+  "SigningCertURL": "https://sns.ap-northeast-1.amazonaws.com/cert.pem",
+  "UnsubscribeURL": "https://localhost/unsubscribe"
+}`
+
+func TestValidate(t *testing.T) {
+	Convey("Given a valid JSON-encoded SNS message", t, func() {
+		defer gock.Off()
+		gock.New("https://sns.ap-northeast-1.amazonaws.com").
+			Get("cert.pem").
+			Reply(200).
+			File("_assets/fakecert.pem")
+
+		Convey("It should return the validated SNSMessage", func() {
+			message, err := Validate(context.Background(), []byte(notificationBody))
+
+			So(err, ShouldBeNil)
+			So(message.MessageId, ShouldEqual, "165545c9-2a5c-472c-8df2-7ff2be2b3b1b")
+			So(message.IsValidated(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a malformed JSON-encoded SNS message", t, func() {
+		Convey("It should return a SNSError of malformed JSON", func() {
+			message, err := Validate(context.Background(), []byte(`{"Type":`))
+
+			So(message, ShouldBeNil)
+			So(errors.Is(err, snsmessage.ErrMalformedJSON), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an invalid SNS message", t, func() {
+		Convey("It should return the SNSError of the validation", func() {
+			message, err := Validate(context.Background(), []byte(`{"Type": "Notification"}`))
+
+			So(message, ShouldBeNil)
+			So(errors.Is(err, snsvalidator.ErrMissingKey), ShouldBeTrue)
+		})
+	})
+}